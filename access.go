@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 访问控制配置：页面与 API 分别使用各自的 CIDR 允许/拒绝列表
+type AccessConfig struct {
+	UIAllow  []string `json:"ui_allow"`  // 为空时允许所有地址
+	UIDeny   []string `json:"ui_deny"`   // 优先于允许列表
+	APIAllow []string `json:"api_allow"` // /api/ 路径
+	APIDeny  []string `json:"api_deny"`
+
+	UploadRate RateLimit `json:"upload_rate"` // 每个 IP 的上传频率
+	LoginRate  RateLimit `json:"login_rate"`  // 每个 IP 的 API 令牌认证失败次数
+
+	// 来自这些地址的请求按 X-Forwarded-For 识别客户端
+	TrustedProxies []string `json:"trusted_proxies"`
+	// unix socket 等没有 IP 地址的客户端视为该地址，默认为 127.0.0.1
+	LocalAddr string `json:"local_addr"`
+}
+
+// 频率限制：window 秒内最多 requests 次，requests 为 0 时不限制
+type RateLimit struct {
+	Requests int `json:"requests"`
+	Window   int `json:"window"`
+}
+
+// 解析后的 CIDR 列表，单个 IP 视为 /32 或 /128
+type prefixList []netip.Prefix
+
+func parsePrefixes(entries []string) (prefixList, error) {
+	var list prefixList
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("无效的地址: %s", entry)
+			}
+			list = append(list, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR: %s", entry)
+		}
+		list = append(list, prefix.Masked())
+	}
+	return list, nil
+}
+
+func (l prefixList) contains(addr netip.Addr) bool {
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// 拒绝列表优先，允许列表为空时允许所有地址
+type accessRule struct {
+	allow prefixList
+	deny  prefixList
+}
+
+func (r accessRule) permits(addr netip.Addr) bool {
+	if r.deny.contains(addr) {
+		return false
+	}
+	return len(r.allow) == 0 || r.allow.contains(addr)
+}
+
+// 每个 IP 一个令牌桶
+type rateLimiter struct {
+	limit   RateLimit
+	mu      sync.Mutex
+	buckets map[netip.Addr]*rateBucket
+	swept   time.Time
+}
+
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	if limit.Requests <= 0 {
+		return nil
+	}
+	if limit.Window <= 0 {
+		limit.Window = 60
+	}
+	return &rateLimiter{limit: limit, buckets: make(map[netip.Addr]*rateBucket)}
+}
+
+// 按经过的时间补充令牌
+func (l *rateLimiter) refill(addr netip.Addr, now time.Time) *rateBucket {
+	capacity := float64(l.limit.Requests)
+	rate := capacity / float64(l.limit.Window)
+
+	// 定期清理已经补满的桶
+	if now.Sub(l.swept) > time.Duration(l.limit.Window)*time.Second {
+		for key, b := range l.buckets {
+			if b.tokens+now.Sub(b.updated).Seconds()*rate >= capacity {
+				delete(l.buckets, key)
+			}
+		}
+		l.swept = now
+	}
+
+	b, ok := l.buckets[addr]
+	if !ok {
+		b = &rateBucket{tokens: capacity, updated: now}
+		l.buckets[addr] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+	return b
+}
+
+// 剩余次数不足时返回需要等待的时间
+func (l *rateLimiter) wait(addr netip.Addr) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.retryAfter(l.refill(addr, time.Now()))
+}
+
+// 消耗一次，次数不足时返回需要等待的时间
+func (l *rateLimiter) take(addr netip.Addr) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.refill(addr, time.Now())
+	if wait := l.retryAfter(b); wait > 0 {
+		return wait
+	}
+	b.tokens--
+	return 0
+}
+
+func (l *rateLimiter) retryAfter(b *rateBucket) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	rate := float64(l.limit.Requests) / float64(l.limit.Window)
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+type accessControl struct {
+	ui, api accessRule
+	upload  *rateLimiter
+	login   *rateLimiter
+	proxies prefixList
+	local   netip.Addr
+}
+
+func newAccessControl(config AccessConfig) (*accessControl, error) {
+	var a accessControl
+	var err error
+	lists := []struct {
+		dst     *prefixList
+		entries []string
+	}{
+		{&a.ui.allow, config.UIAllow},
+		{&a.ui.deny, config.UIDeny},
+		{&a.api.allow, config.APIAllow},
+		{&a.api.deny, config.APIDeny},
+		{&a.proxies, config.TrustedProxies},
+	}
+	for _, l := range lists {
+		if *l.dst, err = parsePrefixes(l.entries); err != nil {
+			return nil, err
+		}
+	}
+	a.upload = newRateLimiter(config.UploadRate)
+	a.login = newRateLimiter(config.LoginRate)
+
+	a.local = netip.MustParseAddr("127.0.0.1")
+	if config.LocalAddr != "" {
+		if a.local, err = netip.ParseAddr(config.LocalAddr); err != nil {
+			return nil, fmt.Errorf("无效的 local_addr: %s", config.LocalAddr)
+		}
+		a.local = a.local.Unmap()
+	}
+	return &a, nil
+}
+
+// 连接对端的地址，unix socket 等没有 IP 地址的对端视为 local_addr
+func (a *accessControl) peerAddr(remote string) netip.Addr {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return a.local
+	}
+	return addr.Unmap()
+}
+
+// 客户端地址：对端是可信代理时，从 X-Forwarded-For 的最右侧向左跳过可信代理，
+// 取第一个不是可信代理的地址；遇到无法解析的条目时停止，使用最后一个可信代理
+func (a *accessControl) clientAddr(remote string, forwarded []string) netip.Addr {
+	addr := a.peerAddr(remote)
+	if !a.proxies.contains(addr) {
+		return addr
+	}
+	var hops []string
+	for _, value := range forwarded {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !a.proxies.contains(addr) {
+			break
+		}
+	}
+	return addr
+}
+
+// 每个升级包计入一次上传频率：断点续传在创建时计数，表单上传在读到文件或开始下载时计数，
+// 以 upload_id 执行升级不再计数。超过限制时返回需要等待的时间
+func (a *accessControl) uploadWait(r *http.Request) time.Duration {
+	if a == nil || a.upload == nil {
+		return 0
+	}
+	addr := a.clientAddr(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+	wait := a.upload.take(addr)
+	if wait > 0 {
+		log.Printf("拒绝上传: %s (来自 %s，上传过于频繁)", r.URL.Path, addr)
+	}
+	return wait
+}
+
+func setRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	setRetryAfter(w, wait)
+	message := "请求过于频繁，请稍后再试"
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusTooManyRequests, map[string]any{"success": false, "message": message})
+		return
+	}
+	http.Error(w, message, http.StatusTooManyRequests)
+}
+
+// 所有路由的访问控制：地址列表、上传频率和 API 令牌认证失败频率
+func (a *accessControl) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := a.clientAddr(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+		rule := a.ui
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			rule = a.api
+		}
+		if !rule.permits(addr) {
+			log.Printf("拒绝访问: %s %s (来自 %s，不在允许范围内)", r.Method, r.URL.Path, addr)
+			http.Error(w, "禁止访问", http.StatusForbidden)
+			return
+		}
+
+		// 携带 Authorization 头的请求视为登录，认证失败的次数受限
+		if a.login != nil && r.Header.Get("Authorization") != "" {
+			if wait := a.login.wait(addr); wait > 0 {
+				log.Printf("拒绝认证: %s %s (来自 %s，认证失败次数过多)", r.Method, r.URL.Path, addr)
+				tooManyRequests(w, r, wait)
+				return
+			}
+			if !hasAPIToken(r) && !hasConfirmToken(r) {
+				log.Printf("API 令牌认证失败: %s %s (来自 %s)", r.Method, r.URL.Path, addr)
+				a.login.take(addr)
+			}
+		}
+
+		// 断点续传只在创建时计数，之后的 PATCH 不受限制
+		if r.Method == http.MethodPost && (r.URL.Path == "/uploads" || r.URL.Path == "/api/uploads") {
+			if wait := a.uploadWait(r); wait > 0 {
+				tooManyRequests(w, r, wait)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
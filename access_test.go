@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	if _, err := parsePrefixes([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("10.0.0.0/33 应当无效")
+	}
+	if _, err := parsePrefixes([]string{"example.com"}); err == nil {
+		t.Error("example.com 应当无效")
+	}
+
+	list, err := parsePrefixes([]string{"10.1.2.3/8", " 192.168.1.5 ", "::ffff:172.16.0.1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.200.0.1", true}, // 前缀被规范化为 10.0.0.0/8
+		{"11.0.0.1", false},
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"172.16.0.1", true}, // IPv4 映射地址按 IPv4 匹配
+		{"fd12::1", true},
+		{"fe80::1", false},
+	}
+	for _, tt := range tests {
+		if got := list.contains(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("contains(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestAccessRulePermits(t *testing.T) {
+	mustParse := func(entries ...string) prefixList {
+		list, err := parsePrefixes(entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+	tests := []struct {
+		name string
+		rule accessRule
+		addr string
+		want bool
+	}{
+		{"无规则", accessRule{}, "203.0.113.1", true},
+		{"在允许列表中", accessRule{allow: mustParse("10.0.0.0/8")}, "10.1.1.1", true},
+		{"不在允许列表中", accessRule{allow: mustParse("10.0.0.0/8")}, "203.0.113.1", false},
+		{"在拒绝列表中", accessRule{deny: mustParse("10.0.0.0/8")}, "10.1.1.1", false},
+		{"拒绝优先", accessRule{allow: mustParse("10.0.0.0/8"), deny: mustParse("10.1.0.0/16")}, "10.1.1.1", false},
+		{"允许列表中未被拒绝的地址", accessRule{allow: mustParse("10.0.0.0/8"), deny: mustParse("10.1.0.0/16")}, "10.2.1.1", true},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.permits(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("%s: permits(%s) = %v, want %v", tt.name, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	if newRateLimiter(RateLimit{}) != nil {
+		t.Fatal("requests 为 0 时不应限制")
+	}
+
+	l := newRateLimiter(RateLimit{Requests: 3, Window: 60})
+	a := netip.MustParseAddr("192.0.2.1")
+	b := netip.MustParseAddr("192.0.2.2")
+	for i := 0; i < 3; i++ {
+		if wait := l.take(a); wait != 0 {
+			t.Fatalf("第 %d 次 take 返回 %v", i+1, wait)
+		}
+	}
+	wait := l.take(a)
+	if wait <= 19*time.Second || wait > 20*time.Second {
+		t.Errorf("用完后应等待约 20 秒，得到 %v", wait)
+	}
+	if l.wait(a) == 0 {
+		t.Error("wait 在用完后应返回非零")
+	}
+	// 每个地址各自计数
+	if wait := l.take(b); wait != 0 {
+		t.Errorf("另一个地址不应受限，得到 %v", wait)
+	}
+
+	// 按经过的时间补充，最多补满容量
+	now := time.Now()
+	l.mu.Lock()
+	if bucket := l.refill(a, now.Add(20*time.Second)); bucket.tokens < 1 || bucket.tokens > 1.01 {
+		t.Errorf("20 秒后应补充 1 次，得到 %v", bucket.tokens)
+	}
+	if bucket := l.refill(a, now.Add(time.Hour)); bucket.tokens != 3 {
+		t.Errorf("补充不应超过容量，得到 %v", bucket.tokens)
+	}
+	l.mu.Unlock()
+}
+
+func TestClientAddr(t *testing.T) {
+	a, err := newAccessControl(AccessConfig{TrustedProxies: []string{"10.0.0.0/8", "127.0.0.1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		want      string
+	}{
+		{"直接连接", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"IPv6", "[2001:db8::1]:5000", nil, "2001:db8::1"},
+		{"IPv4 映射地址", "[::ffff:203.0.113.7]:5000", nil, "203.0.113.7"},
+		{"非可信代理的 X-Forwarded-For 被忽略", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"可信代理", "10.0.0.2:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"跳过多个可信代理", "10.0.0.2:5000", []string{"198.51.100.1, 10.0.0.3", "10.0.0.4"}, "198.51.100.1"},
+		{"客户端伪造的左侧条目被忽略", "10.0.0.2:5000", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"无法解析的条目", "10.0.0.2:5000", []string{"198.51.100.1, unknown"}, "10.0.0.2"},
+		{"可信代理没有 X-Forwarded-For", "10.0.0.2:5000", nil, "10.0.0.2"},
+		{"unix socket", "@", nil, "127.0.0.1"},
+		{"unix socket 空地址", "", nil, "127.0.0.1"},
+		{"unix socket 上的反向代理", "@", []string{"198.51.100.1"}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		if got := a.clientAddr(tt.remote, tt.forwarded); got != netip.MustParseAddr(tt.want) {
+			t.Errorf("%s: clientAddr(%q, %q) = %s, want %s", tt.name, tt.remote, tt.forwarded, got, tt.want)
+		}
+	}
+
+	local, err := newAccessControl(AccessConfig{LocalAddr: "192.0.2.9"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := local.clientAddr("@", []string{"198.51.100.1"}); got != netip.MustParseAddr("192.0.2.9") {
+		t.Errorf("local_addr: got %s", got)
+	}
+	if _, err := newAccessControl(AccessConfig{LocalAddr: "local"}); err == nil {
+		t.Error("无效的 local_addr 应当报错")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"linker-upgrader/upgrader"
+)
+
+func anyServiceEnabled() bool {
+	for _, p := range engine.Profiles() {
+		if p.ServiceEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// 引擎错误对应的 HTTP 状态码
+func errorStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, upgrader.ErrUnknownProfile):
+		return http.StatusNotFound
+	case errors.Is(err, upgrader.ErrBusy), errors.Is(err, upgrader.ErrPolicy):
+		return http.StatusConflict
+	case errors.Is(err, upgrader.ErrFileType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, upgrader.ErrInvalidChannel), errors.Is(err, upgrader.ErrInvalidFilename):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeEngineError(w http.ResponseWriter, err error) {
+	writeJSON(w, errorStatus(err), map[string]any{"success": false, "message": err.Error()})
+}
+
+// 服务控制：/service 供页面表单使用，/api/service 返回 JSON
+// GET 查询状态，POST action=start/stop/restart 执行操作
+func serviceHandler(w http.ResponseWriter, r *http.Request) {
+	isAPI := strings.HasPrefix(r.URL.Path, "/api/")
+	profile := engine.Profile(r.FormValue("profile"))
+	if profile == nil {
+		http.Error(w, "配置档不存在", http.StatusNotFound)
+		return
+	}
+
+	action := r.FormValue("action")
+	if r.Method != http.MethodPost || action == "" || action == "status" {
+		if !isAPI {
+			http.Redirect(w, r, "/?profile="+url.QueryEscape(profile.Name), http.StatusSeeOther)
+			return
+		}
+		status := map[string]any{
+			"profile": profile.Name,
+			"service": profile.ServiceName,
+			"manager": profile.ManagerName(),
+		}
+		statuses, err := engine.Status(r.Context(), profile.Name)
+		if err != nil {
+			writeEngineError(w, err)
+			return
+		}
+		if s := statuses[0].Service; s != nil {
+			status["active"] = s.Active
+			if s.Error != "" {
+				status["error"] = s.Error
+			}
+		} else {
+			status["active"] = false
+			status["error"] = fmt.Sprintf("配置档 %s 未启用服务管理", profile.Name)
+		}
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+
+	logs, err := engine.ControlService(r.Context(), profile.Name, action, r.RemoteAddr)
+	message := fmt.Sprintf("服务 %s 执行 %s 成功", profile.ServiceName, action)
+	if err != nil {
+		message = fmt.Sprintf("服务 %s 执行 %s 失败: %v", profile.ServiceName, action, err)
+	}
+
+	if isAPI {
+		code := http.StatusOK
+		if err != nil {
+			code = http.StatusInternalServerError
+		}
+		writeJSON(w, code, map[string]any{"success": err == nil, "message": message, "output": logs})
+		return
+	}
+
+	if err != nil {
+		showResult(w, r, http.StatusOK, profile, message, "error", logs)
+		return
+	}
+	showResult(w, r, http.StatusOK, profile, message, "success", logs)
+}
+
+// 确认接口：POST /api/confirm?profile=<name>[&version=<version>]
+func confirmHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "仅支持 POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := engine.Confirm(r.FormValue("profile"), r.FormValue("version"), r.RemoteAddr)
+	if errors.Is(err, upgrader.ErrUnknownProfile) {
+		http.Error(w, "配置档不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]any{"success": false, "message": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"success": true, "message": "升级已确认"})
+}
+
+// 备份列表 API：GET /api/backups?profile=<name>
+func backupsHandler(w http.ResponseWriter, r *http.Request) {
+	backups, err := engine.Backups(r.URL.Query().Get("profile"))
+	if err != nil {
+		writeEngineError(w, err)
+		return
+	}
+	if backups == nil {
+		backups = []upgrader.BackupInfo{}
+	}
+	writeJSON(w, http.StatusOK, backups)
+}
+
+// 恢复接口：POST /api/restore?profile=<name>[&backup=<备份文件名>]，未指定备份时使用最新的备份
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "仅支持 POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := upgrader.RestoreRequest{
+		Profile: r.FormValue("profile"),
+		Backup:  r.FormValue("backup"),
+		Remote:  r.RemoteAddr,
+	}
+	result, err := engine.Restore(context.WithoutCancel(r.Context()), req, nil)
+	writeJSON(w, errorStatus(err), result)
+}
+
+// 切换发布渠道：/channel 供页面表单使用，/api/channel 返回 JSON
+// POST profile=<name>&channel=<channel>，启用发布源时随后立即检查更新
+func channelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "仅支持 POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channel := r.FormValue("channel")
+	err := engine.SetChannel(r.FormValue("profile"), channel, r.RemoteAddr)
+	message := "发布渠道已切换为 " + channel
+	if err != nil {
+		message = "切换发布渠道失败: " + err.Error()
+	} else if autoUpdater != nil {
+		autoUpdater.checkNow()
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeJSON(w, errorStatus(err), map[string]any{"success": err == nil, "message": message})
+		return
+	}
+	profile := engine.Profile(r.FormValue("profile"))
+	if profile == nil {
+		http.Error(w, "配置档不存在", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		showResult(w, r, http.StatusOK, profile, message, "error", "")
+		return
+	}
+	showResult(w, r, http.StatusOK, profile, message, "success", "")
+}
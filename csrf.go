@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	sessionCookieName = "upgrader_session"
+	csrfFieldName     = "csrf_token"
+	csrfHeaderName    = "X-CSRF-Token"
+)
+
+// CSRF 令牌签名密钥，每次启动重新生成，重启后页面需刷新
+var csrfSecret = func() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}()
+
+// 会话的 CSRF 令牌：会话 ID 的 HMAC，无需在服务端保存
+func csrfTokenFor(sessionID string) string {
+	mac := hmac.New(sha256.New, csrfSecret)
+	mac.Write([]byte(sessionID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 获取请求的会话 ID，没有会话时创建并写入 Cookie
+func ensureSession(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(sessionCookieName); err == nil && c.Value != "" {
+		return c.Value
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return id
+}
+
+// Authorization 的值是否为 Bearer <token>，token 为空时不匹配
+func bearerMatches(authorization, token string) bool {
+	if token == "" {
+		return false
+	}
+	got, ok := strings.CutPrefix(authorization, "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// Authorization 是否携带有效的 API 令牌，HTTP 请求头与 gRPC 元数据使用同一校验
+func validAPIToken(authorization string) bool {
+	return bearerMatches(authorization, appConfig.APIToken)
+}
+
+// 请求是否携带有效的 API 令牌（Authorization: Bearer <api_token>）
+func hasAPIToken(r *http.Request) bool {
+	return validAPIToken(r.Header.Get("Authorization"))
+}
+
+// 确认请求是否携带所确认配置档的 confirm_token，被管理的应用只需持有该令牌
+func hasConfirmToken(r *http.Request) bool {
+	if r.URL.Path != "/api/confirm" {
+		return false
+	}
+	p := engine.Profile(r.FormValue("profile"))
+	return p != nil && bearerMatches(r.Header.Get("Authorization"), p.ConfirmToken)
+}
+
+// 校验 CSRF 令牌是否与会话 Cookie 对应
+func validCSRFToken(r *http.Request, token string) bool {
+	c, err := r.Cookie(sessionCookieName)
+	if err != nil || c.Value == "" || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(csrfTokenFor(c.Value)))
+}
+
+// 无需读取表单即可通过 CSRF 校验：GET/HEAD/OPTIONS 请求、API 令牌、确认令牌或请求头 X-CSRF-Token
+func csrfExempt(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions || hasAPIToken(r) ||
+		hasConfirmToken(r) || validCSRFToken(r, r.Header.Get(csrfHeaderName))
+}
+
+func rejectCSRF(w http.ResponseWriter, r *http.Request) {
+	log.Printf("拒绝 CSRF 校验失败的请求: %s %s (来自 %s)", r.Method, r.URL.Path, r.RemoteAddr)
+	message := "CSRF 校验失败，请刷新页面后重试"
+	if wantsJSON(r) {
+		writeJSON(w, http.StatusForbidden, map[string]any{"success": false, "message": message})
+		return
+	}
+	http.Error(w, message, http.StatusForbidden)
+}
+
+// 修改状态的路由：POST 请求需要有效的 CSRF 令牌（请求头或表单字段 csrf_token），携带 API 令牌的请求除外
+func csrfProtect(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if csrfExempt(r) || validCSRFToken(r, r.FormValue(csrfFieldName)) {
+			next(w, r)
+			return
+		}
+		rejectCSRF(w, r)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"linker-upgrader/upgrader"
+)
+
+// 从 URL 下载升级包的配置
+type DownloadConfig struct {
+	Timeout    int    `json:"timeout"`     // 整个下载的超时时间（秒），默认 1800
+	Retries    int    `json:"retries"`     // 连接中断后断点续传的次数，默认 5
+	AuthHeader string `json:"auth_header"` // 请求未指定时使用的 Authorization 请求头
+	// 只向这些主机发送 auth_header，为空时不发送
+	AuthHosts []string `json:"auth_hosts"`
+}
+
+var (
+	// 下载地址或文件名无效
+	errInvalidDownload = errors.New("升级包地址无效")
+	// 下载的升级包超过 max_file_size
+	errDownloadTooLarge = errors.New("文件大小超过限制")
+)
+
+// 下载请求
+type downloadRequest struct {
+	URL      string
+	Filename string // 为空时取 URL 路径的最后一段
+	SHA256   string // 可选，下载完成后校验
+	Auth     string // 可选，Authorization 请求头
+	Uploader string // 发起方地址，写入上传元数据
+}
+
+// 下载升级包到上传目录，与上传的文件使用相同的存储和元数据。
+// 连接中断时使用 Range 请求从已下载的位置继续；超过大小限制、超时或校验和不一致时删除
+func downloadPackage(ctx context.Context, req downloadRequest) (*uploadMeta, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %s", errInvalidDownload, req.URL)
+	}
+	filename := req.Filename
+	if filename == "" {
+		filename = path.Base(u.Path)
+	}
+	filename = sanitizeFilename(filename)
+	if filename == "" {
+		return nil, fmt.Errorf("%w: 无法确定升级包文件名，请指定 filename", errInvalidDownload)
+	}
+	if req.Auth == "" && authHostAllowed(u) {
+		req.Auth = appConfig.Download.AuthHeader
+	}
+
+	timeout := time.Duration(appConfig.Download.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Minute
+	}
+	retries := appConfig.Download.Retries
+	if retries <= 0 {
+		retries = 5
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := os.MkdirAll(appConfig.UploadDir, upgrader.ParsePermission(appConfig.DirPermission)); err != nil {
+		return nil, fmt.Errorf("创建上传目录失败: %v", err)
+	}
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+	meta := &uploadMeta{ID: id, Filename: filename, Uploader: req.Uploader}
+	f, err := os.OpenFile(uploadPartPath(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("创建文件失败: %v", err)
+	}
+	d := &downloader{req: req, file: f, total: -1, maxSize: appConfig.MaxFileSize << 20}
+	for attempt := 0; ; attempt++ {
+		retry, fetchErr := d.fetch(ctx)
+		if fetchErr == nil {
+			break
+		}
+		err = fetchErr
+		if !retry || attempt >= retries || ctx.Err() != nil {
+			break
+		}
+		wait := min(time.Duration(1<<attempt)*time.Second, 30*time.Second)
+		log.Printf("下载升级包中断 (已下载 %d bytes)，%v 后重试: %v", d.offset, wait, fetchErr)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+		err = nil
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = completeResumable(meta)
+	}
+	if err == nil && req.SHA256 != "" && !strings.EqualFold(req.SHA256, meta.SHA256) {
+		err = fmt.Errorf("升级包 SHA256 校验失败")
+	}
+	if err != nil {
+		removeUpload(id)
+		if errors.Is(err, errDownloadTooLarge) {
+			return nil, fmt.Errorf("%w (%dMB)", errDownloadTooLarge, appConfig.MaxFileSize)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("下载升级包超时 (%v)", timeout)
+		}
+		return nil, fmt.Errorf("下载升级包失败: %v", err)
+	}
+	log.Printf("已下载升级包: %s (ID: %s), 大小: %d bytes", meta.Filename, meta.ID, meta.Size)
+	return meta, nil
+}
+
+// 配置的 auth_header 只发送给 auth_hosts 中的主机，条目为主机名或 主机名:端口
+func authHostAllowed(u *url.URL) bool {
+	for _, host := range appConfig.Download.AuthHosts {
+		host = strings.TrimSpace(host)
+		if strings.EqualFold(host, u.Host) || strings.EqualFold(host, u.Hostname()) {
+			return true
+		}
+	}
+	return false
+}
+
+// 下载使用的 HTTP 客户端：重定向到其他主机时不发送 Authorization 请求头
+var downloadClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("重定向次数过多")
+		}
+		if !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+			req.Header.Del("Authorization")
+		}
+		return nil
+	},
+}
+
+// 断点续传下载的状态
+type downloader struct {
+	req       downloadRequest
+	file      *os.File
+	offset    int64  // 已写入的字节数
+	total     int64  // 文件总大小，未知时为 -1
+	validator string // ETag 或 Last-Modified，续传时确认文件未变化
+	maxSize   int64
+}
+
+// 从已下载的位置继续下载，返回错误时 retry 表示是否可以重试
+func (d *downloader) fetch(ctx context.Context) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.req.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	if d.req.Auth != "" {
+		req.Header.Set("Authorization", d.req.Auth)
+	}
+	if d.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+		if d.validator != "" {
+			req.Header.Set("If-Range", d.validator)
+		}
+	}
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		// 首次下载，或服务器不支持续传、文件已变化，从头开始
+		if d.offset > 0 {
+			if err := d.reset(); err != nil {
+				return false, err
+			}
+		}
+		d.total = resp.ContentLength
+	case resp.StatusCode == http.StatusPartialContent:
+		start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != d.offset {
+			return false, fmt.Errorf("服务器返回的 Content-Range 无效: %s", resp.Header.Get("Content-Range"))
+		}
+		d.total = total
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && d.offset > 0 && d.offset == d.total:
+		return false, nil
+	default:
+		return resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests, fmt.Errorf("服务器返回 %s", resp.Status)
+	}
+	if d.maxSize > 0 && d.total > d.maxSize {
+		return false, errDownloadTooLarge
+	}
+	if v := resp.Header.Get("ETag"); v != "" && !strings.HasPrefix(v, "W/") {
+		d.validator = v
+	} else {
+		d.validator = resp.Header.Get("Last-Modified")
+	}
+
+	var body io.Reader = resp.Body
+	if d.maxSize > 0 {
+		// 多读一个字节用于判断是否超出限制
+		body = io.LimitReader(resp.Body, d.maxSize-d.offset+1)
+	}
+	n, err := io.Copy(d.file, body)
+	d.offset += n
+	switch {
+	case d.maxSize > 0 && d.offset > d.maxSize:
+		return false, errDownloadTooLarge
+	case err != nil:
+		return true, err
+	case d.total >= 0 && d.offset != d.total:
+		return true, fmt.Errorf("连接提前结束 (%d/%d bytes)", d.offset, d.total)
+	}
+	return false, nil
+}
+
+func (d *downloader) reset() error {
+	d.offset = 0
+	if err := d.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := d.file.Seek(0, io.SeekStart)
+	return err
+}
+
+// 解析 Content-Range: bytes <start>-<end>/<total>，总大小未知时 total 为 -1
+func parseContentRange(header string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if size == "*" {
+		return start, -1, true
+	}
+	total, err = strconv.ParseInt(size, 10, 64)
+	return start, total, err == nil
+}
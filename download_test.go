@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadResume(t *testing.T) {
+	setupUploadDir(t)
+	content := strings.Repeat("0123456789", 100)
+
+	var mu sync.Mutex
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range")+"|"+r.Header.Get("If-Range"))
+		first := len(ranges) == 1
+		mu.Unlock()
+		if first {
+			// 第一次只发送一半数据后断开连接
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\nETag: \"v1\"\r\n\r\n%s", len(content), content[:400])
+			buf.Flush()
+			conn.Close()
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "app.tar.gz", time.Time{}, strings.NewReader(content))
+	}))
+	defer srv.Close()
+
+	meta, err := downloadPackage(context.Background(), downloadRequest{URL: srv.URL + "/files/app-1.0.0.tar.gz"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Filename != "app-1.0.0.tar.gz" || meta.Size != int64(len(content)) {
+		t.Errorf("meta = %+v", meta)
+	}
+	if data, _ := os.ReadFile(meta.path()); string(data) != content {
+		t.Error("下载内容不一致")
+	}
+	if len(ranges) != 2 || ranges[1] != `bytes=400-|"v1"` {
+		t.Errorf("请求 = %q，第二次应从 400 字节处续传", ranges)
+	}
+}
+
+func TestDownloadTooLarge(t *testing.T) {
+	setupUploadDir(t)
+	appConfig.MaxFileSize = 1
+	big := strings.Repeat("x", 1<<20+1)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"Content-Length 超出", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", fmt.Sprint(len(big)))
+			w.Write([]byte(big[:10]))
+		}},
+		{"长度未知", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(big[:100]))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(big[100:]))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			_, err := downloadPackage(context.Background(), downloadRequest{URL: srv.URL + "/app.tar.gz"})
+			if !errors.Is(err, errDownloadTooLarge) {
+				t.Fatalf("err = %v, want errDownloadTooLarge", err)
+			}
+			if entries, _ := os.ReadDir(appConfig.UploadDir); len(entries) != 0 {
+				t.Errorf("上传目录应为空，实际 %d 个文件", len(entries))
+			}
+		})
+	}
+}
+
+func TestDownloadInvalid(t *testing.T) {
+	setupUploadDir(t)
+	for _, u := range []string{"ftp://example.com/app.tar.gz", "file:///etc/passwd", "http:///app.tar.gz", "http://example.com/"} {
+		if _, err := downloadPackage(context.Background(), downloadRequest{URL: u}); !errors.Is(err, errInvalidDownload) {
+			t.Errorf("%s: err = %v", u, err)
+		}
+	}
+}
+
+func TestDownloadAuth(t *testing.T) {
+	setupUploadDir(t)
+	appConfig.Download.AuthHeader = "Bearer configured"
+
+	var mu sync.Mutex
+	received := make(map[string]string)
+	record := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			received[name] = r.Header.Get("Authorization")
+			mu.Unlock()
+			w.Write([]byte("package"))
+		}
+	}
+	other := httptest.NewServer(record("other"))
+	defer other.Close()
+	// 另一台主机：同一服务器通过 localhost 访问时主机名不同
+	otherURL, _ := url.Parse(other.URL)
+	otherURL.Host = "localhost:" + otherURL.Port()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app.bin", record("origin"))
+	mux.HandleFunc("/same/app.bin", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/app.bin", http.StatusFound)
+	})
+	mux.HandleFunc("/cross/app.bin", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, otherURL.String()+"/app.bin", http.StatusFound)
+	})
+	origin := httptest.NewServer(mux)
+	defer origin.Close()
+	originURL, _ := url.Parse(origin.URL)
+
+	tests := []struct {
+		name      string
+		authHosts []string
+		path      string
+		auth      string
+		want      map[string]string
+	}{
+		{"未配置 auth_hosts", nil, "/app.bin", "", map[string]string{"origin": ""}},
+		{"主机不匹配", []string{"artifacts.example.com"}, "/app.bin", "", map[string]string{"origin": ""}},
+		{"主机名匹配", []string{originURL.Hostname()}, "/app.bin", "", map[string]string{"origin": "Bearer configured"}},
+		{"主机名和端口匹配", []string{originURL.Host}, "/app.bin", "", map[string]string{"origin": "Bearer configured"}},
+		{"端口不匹配", []string{originURL.Hostname() + ":1"}, "/app.bin", "", map[string]string{"origin": ""}},
+		{"请求指定的 auth", nil, "/app.bin", "Bearer request", map[string]string{"origin": "Bearer request"}},
+		{"同一主机重定向", []string{originURL.Hostname()}, "/same/app.bin", "", map[string]string{"origin": "Bearer configured"}},
+		{"跨主机重定向", []string{originURL.Hostname()}, "/cross/app.bin", "", map[string]string{"other": ""}},
+		{"跨主机重定向不发送请求指定的 auth", nil, "/cross/app.bin", "Bearer request", map[string]string{"other": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appConfig.Download.AuthHosts = tt.authHosts
+			mu.Lock()
+			clear(received)
+			mu.Unlock()
+			meta, err := downloadPackage(context.Background(), downloadRequest{URL: origin.URL + tt.path, Auth: tt.auth})
+			if err != nil {
+				t.Fatal(err)
+			}
+			removeUpload(meta.ID)
+			mu.Lock()
+			defer mu.Unlock()
+			for name, want := range tt.want {
+				got, ok := received[name]
+				if !ok {
+					t.Fatalf("%s 未收到请求", name)
+				}
+				if got != want {
+					t.Errorf("%s 收到 Authorization %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
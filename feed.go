@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"linker-upgrader/upgrader"
+)
+
+// 发布源自动升级配置，未配置时不启用
+type FeedConfig struct {
+	URL            string   `json:"url"`             // 发布源 JSON 地址
+	Interval       int      `json:"interval"`        // 检查间隔（秒），默认 3600
+	Jitter         int      `json:"jitter"`          // 每次检查随机推迟的最大秒数，避免大量设备同时请求
+	Profiles       []string `json:"profiles"`        // 自动升级的配置档，为空时为全部
+	PublicKey      string   `json:"public_key"`      // base64 编码的 ed25519 公钥，配置后要求发布签名
+	AuthHeader     string   `json:"auth_header"`     // 获取发布源时使用的 Authorization 请求头
+	InstallUnknown bool     `json:"install_unknown"` // 已部署版本未知时是否安装最新发布，默认跳过
+}
+
+const (
+	defaultFeedInterval = time.Hour
+	// 失败后的首次重试间隔，之后每次加倍，不超过检查间隔
+	feedRetryDelay = 30 * time.Second
+	// 发布源 JSON 的大小上限
+	maxFeedSize = 1 << 20
+)
+
+// 发布源：{"releases": [...]}，或按渠道分组的 {"channels": {"stable": [...], "beta": [...]}}，两者可以同时使用
+type releaseFeed struct {
+	Releases []feedRelease            `json:"releases"`
+	Channels map[string][]feedRelease `json:"channels"`
+}
+
+// 发布条目。signature 为对升级包 SHA256 摘要（32 字节）的 ed25519 签名，base64 编码
+type feedRelease struct {
+	Version   string `json:"version"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+	Channel   string `json:"channel"`  // 为空时为 stable；channels 分组中的条目取分组名
+	Profile   string `json:"profile"`  // 为空时适用于所有配置档
+	Filename  string `json:"filename"` // 为空时取 URL 路径的文件名
+}
+
+// 配置档的检查结果
+const (
+	feedUpToDate  = "up_to_date"
+	feedAhead     = "ahead" // 已部署版本高于渠道的最新发布，例如从 beta 切换到 stable
+	feedUpgraded  = "upgraded"
+	feedFailed    = "failed"
+	feedSkipped   = "skipped"
+	feedNoRelease = "no_release"
+)
+
+type feedProfileStatus struct {
+	Profile string `json:"profile"`
+	Channel string `json:"channel"`
+	Current string `json:"current"` // 已部署版本
+	Latest  string `json:"latest"`  // 发布源中的最新版本
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+// 自动升级状态，GET /api/feed 返回
+type feedStatus struct {
+	URL       string              `json:"url"`
+	Checking  bool                `json:"checking"`
+	LastCheck time.Time           `json:"last_check"`
+	NextCheck time.Time           `json:"next_check"`
+	Failures  int                 `json:"failures"` // 连续失败次数
+	Error     string              `json:"error,omitempty"`
+	Profiles  []feedProfileStatus `json:"profiles"`
+}
+
+// 按名称查找配置档的检查结果
+func (s *feedStatus) profile(name string) *feedProfileStatus {
+	for i := range s.Profiles {
+		if s.Profiles[i].Profile == name {
+			return &s.Profiles[i]
+		}
+	}
+	return nil
+}
+
+type feedAgent struct {
+	config   *FeedConfig
+	interval time.Duration
+	jitter   time.Duration
+	key      ed25519.PublicKey
+	client   *http.Client
+	trigger  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu     sync.Mutex
+	status feedStatus
+	// 升级失败的发布（配置档 -> SHA256），不再自动重试，直到发布源出现新的版本
+	failed map[string]string
+}
+
+// 自动升级，启用后由 /feed 页面表单和 /api/feed 触发立即检查
+var autoUpdater *feedAgent
+
+// 定期获取发布源，发现比已部署版本更新的发布时自动下载并升级
+func startFeed(config *FeedConfig) (*feedAgent, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("发布源地址无效: %s", config.URL)
+	}
+	for _, name := range config.Profiles {
+		if engine.Profile(name) == nil {
+			return nil, fmt.Errorf("%w: %s", upgrader.ErrUnknownProfile, name)
+		}
+	}
+
+	a := &feedAgent{
+		config:   config,
+		interval: time.Duration(config.Interval) * time.Second,
+		jitter:   time.Duration(max(config.Jitter, 0)) * time.Second,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		trigger:  make(chan struct{}, 1),
+		failed:   make(map[string]string),
+	}
+	if a.interval <= 0 {
+		a.interval = defaultFeedInterval
+	}
+	if config.PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(config.PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("发布源公钥无效，应为 base64 编码的 ed25519 公钥")
+		}
+		a.key = key
+	}
+	a.status.URL = config.URL
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	go a.run()
+	return a, nil
+}
+
+// 自动升级的配置档
+func (a *feedAgent) profiles() []*upgrader.Profile {
+	if len(a.config.Profiles) == 0 {
+		return engine.Profiles()
+	}
+	var profiles []*upgrader.Profile
+	for _, name := range a.config.Profiles {
+		profiles = append(profiles, engine.Profile(name))
+	}
+	return profiles
+}
+
+// 立即检查。正在检查时触发的请求在本次检查结束后再执行一次，多次触发合并为一次
+func (a *feedAgent) checkNow() {
+	select {
+	case a.trigger <- struct{}{}:
+	default:
+	}
+}
+
+func (a *feedAgent) close() {
+	a.cancel()
+}
+
+func (a *feedAgent) snapshot() feedStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	status := a.status
+	status.Profiles = slices.Clone(a.status.Profiles)
+	return status
+}
+
+// 首次检查随机推迟，之后按检查间隔加随机推迟执行；失败时按指数退避提前重试
+func (a *feedAgent) run() {
+	delay := a.randomJitter()
+	for {
+		a.mu.Lock()
+		a.status.NextCheck = time.Now().Add(delay)
+		a.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-a.trigger:
+			timer.Stop()
+		case <-a.ctx.Done():
+			timer.Stop()
+			return
+		}
+		delay = a.nextDelay(a.check())
+	}
+}
+
+func (a *feedAgent) randomJitter() time.Duration {
+	if a.jitter <= 0 {
+		return 0
+	}
+	return rand.N(a.jitter)
+}
+
+func (a *feedAgent) nextDelay(err error) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err == nil {
+		a.status.Failures = 0
+		return a.interval + a.randomJitter()
+	}
+	a.status.Failures++
+	backoff := feedRetryDelay << min(a.status.Failures-1, 16)
+	return min(backoff, a.interval) + a.randomJitter()
+}
+
+// 获取发布源并依次检查各配置档，返回需要重试的错误
+func (a *feedAgent) check() error {
+	a.mu.Lock()
+	a.status.Checking = true
+	a.mu.Unlock()
+
+	feed, err := a.fetch()
+	var results []feedProfileStatus
+	var errs []error
+	if err == nil {
+		for _, p := range a.profiles() {
+			status, err := a.checkProfile(p, feed)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("[%s] %v", p.Name, err))
+			}
+			results = append(results, status)
+		}
+		err = errors.Join(errs...)
+	} else {
+		err = fmt.Errorf("获取发布源失败: %v", err)
+	}
+	if err != nil {
+		log.Printf("自动升级检查失败: %v", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.Checking = false
+	a.status.LastCheck = time.Now()
+	a.status.Error = ""
+	if err != nil {
+		a.status.Error = err.Error()
+	}
+	if results != nil {
+		a.status.Profiles = results
+	}
+	return err
+}
+
+func (a *feedAgent) fetch() (*releaseFeed, error) {
+	req, err := http.NewRequestWithContext(a.ctx, http.MethodGet, a.config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if a.config.AuthHeader != "" {
+		req.Header.Set("Authorization", a.config.AuthHeader)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("服务器返回 %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFeedSize {
+		return nil, fmt.Errorf("发布源超过 %d bytes", maxFeedSize)
+	}
+	var feed releaseFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("解析发布源失败: %v", err)
+	}
+	for channel, releases := range feed.Channels {
+		for _, r := range releases {
+			r.Channel = channel
+			feed.Releases = append(feed.Releases, r)
+		}
+	}
+	return &feed, nil
+}
+
+// 配置档在渠道中版本最高的发布，版本号无法按 semver 解析的条目被忽略
+func (f *releaseFeed) latest(profile, channel string) *feedRelease {
+	var latest *feedRelease
+	for i := range f.Releases {
+		r := &f.Releases[i]
+		rc := r.Channel
+		if rc == "" {
+			rc = upgrader.DefaultChannel
+		}
+		if rc != channel || (r.Profile != "" && r.Profile != profile) {
+			continue
+		}
+		if _, ok := upgrader.CompareVersions(r.Version, r.Version); !ok {
+			continue
+		}
+		if latest == nil {
+			latest = r
+		} else if cmp, _ := upgrader.CompareVersions(r.Version, latest.Version); cmp > 0 {
+			latest = r
+		}
+	}
+	return latest
+}
+
+// 检查单个配置档，渠道中的发布比已部署版本新时下载并升级。
+// 已部署版本更高时（例如从 beta 切换到 stable）保持不变，直到渠道发布更高的版本；
+// 只有配置档的 downgrade_policy 为 allow 时才降级到渠道的最新发布。
+// 只有下载失败或配置档忙等可以重试的情况返回错误，升级失败记录后不再重试同一发布
+func (a *feedAgent) checkProfile(p *upgrader.Profile, feed *releaseFeed) (feedProfileStatus, error) {
+	channel := p.ActiveChannel()
+	status := feedProfileStatus{Profile: p.Name, Channel: channel, State: feedUpToDate}
+	deployed := p.Deployed()
+	if deployed != nil {
+		status.Current = deployed.Version
+	}
+	release := feed.latest(p.Name, channel)
+	if release == nil {
+		status.State = feedNoRelease
+		status.Message = fmt.Sprintf("发布源中没有 %s 渠道的发布", channel)
+		return status, nil
+	}
+	status.Latest = release.Version
+
+	if deployed != nil && deployed.SHA256 != "" && strings.EqualFold(deployed.SHA256, release.SHA256) {
+		return status, nil
+	}
+	if deployed == nil || deployed.Version == "" {
+		// 无法判断发布是否更新，可能是降级
+		if !a.config.InstallUnknown {
+			status.State = feedSkipped
+			status.Message = "已部署版本未知，未配置 install_unknown 时不自动升级"
+			return status, nil
+		}
+	} else {
+		cmp, ok := upgrader.CompareVersions(release.Version, deployed.Version)
+		if !ok {
+			status.State = feedSkipped
+			status.Message = fmt.Sprintf("已部署版本 %s 无法与发布版本比较", deployed.Version)
+			return status, nil
+		}
+		switch {
+		case cmp == 0:
+			return status, nil
+		case cmp < 0 && p.DowngradePolicy != upgrader.PolicyAllow:
+			status.State = feedAhead
+			status.Message = fmt.Sprintf("已部署版本高于 %s 渠道的最新发布，等待该渠道发布更高的版本", channel)
+			return status, nil
+		}
+	}
+
+	a.mu.Lock()
+	failedHash := a.failed[p.Name]
+	a.mu.Unlock()
+	if strings.EqualFold(failedHash, release.SHA256) {
+		status.State = feedFailed
+		status.Message = fmt.Sprintf("版本 %s 升级失败，等待新的发布", release.Version)
+		return status, nil
+	}
+	if err := a.verify(release); err != nil {
+		status.State = feedSkipped
+		status.Message = err.Error()
+		log.Printf("[%s] 忽略发布 %s: %v", p.Name, release.Version, err)
+		return status, nil
+	}
+
+	current := "未知"
+	if deployed != nil {
+		current = deployed.DisplayVersion()
+	}
+	log.Printf("[%s] %s 渠道发布 %s (当前 %s)，开始下载: %s", p.Name, channel, release.Version, current, release.URL)
+	remote := "feed:" + a.config.URL
+	upload, err := downloadPackage(a.ctx, downloadRequest{
+		URL:      release.URL,
+		Filename: release.Filename,
+		SHA256:   release.SHA256,
+		Uploader: remote,
+	})
+	if err != nil {
+		status.State = feedFailed
+		status.Message = err.Error()
+		return status, err
+	}
+
+	// 退出时不中断进行中的升级
+	result, err := engine.Upgrade(context.WithoutCancel(a.ctx), upload.upgradeRequest(p.Name, false, remote), nil)
+	status.Message = result.Message
+	if errors.Is(err, upgrader.ErrBusy) {
+		status.State = feedFailed
+		return status, err
+	}
+	if err != nil || !result.Success {
+		status.State = feedFailed
+		a.mu.Lock()
+		a.failed[p.Name] = release.SHA256
+		a.mu.Unlock()
+		log.Printf("[%s] 自动升级到 %s 失败: %s", p.Name, release.Version, result.Message)
+		return status, nil
+	}
+	status.State = feedUpgraded
+	if deployed := p.Deployed(); deployed != nil {
+		status.Current = deployed.Version
+	}
+	log.Printf("[%s] 已自动升级到 %s", p.Name, release.Version)
+	return status, nil
+}
+
+// 检查发布条目：必须提供地址和 SHA256，配置公钥时签名必须有效
+func (a *feedAgent) verify(r *feedRelease) error {
+	if r.URL == "" {
+		return fmt.Errorf("发布缺少 url")
+	}
+	digest, err := hex.DecodeString(r.SHA256)
+	if err != nil || len(digest) != 32 {
+		return fmt.Errorf("发布的 sha256 无效")
+	}
+	if a.key == nil {
+		return nil
+	}
+	if r.Signature == "" {
+		return fmt.Errorf("发布缺少签名")
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil || !ed25519.Verify(a.key, digest, signature) {
+		return fmt.Errorf("发布签名校验失败")
+	}
+	return nil
+}
+
+// 自动升级：GET /api/feed 查询状态，POST /feed（页面表单）或 /api/feed 立即检查
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	isAPI := strings.HasPrefix(r.URL.Path, "/api/")
+	if autoUpdater == nil {
+		http.Error(w, "未启用发布源自动升级", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		if !isAPI {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		writeJSON(w, http.StatusOK, autoUpdater.snapshot())
+		return
+	}
+
+	autoUpdater.checkNow()
+	log.Printf("立即检查更新 (来自 %s)", r.RemoteAddr)
+	message := "已开始检查更新"
+	if isAPI {
+		writeJSON(w, http.StatusAccepted, map[string]any{"success": true, "message": message})
+		return
+	}
+	profile := engine.Profile(r.FormValue("profile"))
+	if profile == nil {
+		profile = engine.Profile("")
+	}
+	showResult(w, r, http.StatusOK, profile, message, "success", "")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 本地发布源：/feed.json 返回 feed，/files/ 下为升级包
+type testFeedServer struct {
+	*testPackageServer
+	feed releaseFeed
+	auth string // 最近一次获取发布源的 Authorization
+}
+
+func newTestFeedServer(t *testing.T) *testFeedServer {
+	s := &testFeedServer{testPackageServer: newTestPackageServer(t)}
+	s.mux.HandleFunc("/feed.json", func(w http.ResponseWriter, r *http.Request) {
+		s.auth = r.Header.Get("Authorization")
+		json.NewEncoder(w).Encode(s.feed)
+	})
+	return s
+}
+
+// 发布一个版本
+func (s *testFeedServer) publish(t *testing.T, version, channel string) {
+	url, hash := s.add(t, version)
+	s.feed.Releases = append(s.feed.Releases, feedRelease{Version: version, URL: url, SHA256: hash, Channel: channel})
+}
+
+func TestFeedFetch(t *testing.T) {
+	srv := newTestFeedServer(t)
+	srv.feed = releaseFeed{
+		Releases: []feedRelease{{Version: "1.0.0"}},
+		Channels: map[string][]feedRelease{"beta": {{Version: "1.1.0-beta.1"}}},
+	}
+	a := &feedAgent{config: &FeedConfig{URL: srv.URL + "/feed.json", AuthHeader: "Bearer feed"}, client: srv.Client(), ctx: context.Background()}
+	feed, err := a.fetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.auth != "Bearer feed" {
+		t.Errorf("Authorization = %q", srv.auth)
+	}
+	if len(feed.Releases) != 2 || feed.Releases[1].Channel != "beta" {
+		t.Errorf("channels 分组应合并到 releases 并带上渠道: %+v", feed.Releases)
+	}
+
+	failures := map[string]http.HandlerFunc{
+		"服务器错误":   func(w http.ResponseWriter, r *http.Request) { http.Error(w, "down", http.StatusServiceUnavailable) },
+		"无效 JSON": func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("{")) },
+		"超过大小限制":  func(w http.ResponseWriter, r *http.Request) { w.Write(bytes.Repeat([]byte(" "), maxFeedSize+1)) },
+	}
+	for name, handler := range failures {
+		bad := httptest.NewServer(handler)
+		a := &feedAgent{config: &FeedConfig{URL: bad.URL}, client: bad.Client(), ctx: context.Background()}
+		if _, err := a.fetch(); err == nil {
+			t.Errorf("%s: 应当返回错误", name)
+		}
+		bad.Close()
+	}
+}
+
+func TestReleaseFeedLatest(t *testing.T) {
+	feed := releaseFeed{Releases: []feedRelease{
+		{Version: "1.0.0"},
+		{Version: "1.2.0"},
+		{Version: "1.10.0", Profile: "other"},
+		{Version: "nightly"},
+		{Version: "2.0.0-beta.1", Channel: "beta"},
+		{Version: "1.1.0", Channel: "stable"},
+	}}
+	tests := []struct {
+		profile, channel, want string
+	}{
+		{"default", "stable", "1.2.0"},
+		{"other", "stable", "1.10.0"},
+		{"default", "beta", "2.0.0-beta.1"},
+		{"default", "dev", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if r := feed.latest(tt.profile, tt.channel); r != nil {
+			got = r.Version
+		}
+		if got != tt.want {
+			t.Errorf("latest(%s, %s) = %q, want %q", tt.profile, tt.channel, got, tt.want)
+		}
+	}
+}
+
+func TestFeedVerify(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	digest := sha256.Sum256([]byte("package"))
+	hash := hex.EncodeToString(digest[:])
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, digest[:]))
+	other := base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte("other")))
+
+	tests := []struct {
+		name    string
+		key     ed25519.PublicKey
+		release feedRelease
+		ok      bool
+	}{
+		{"未配置公钥", nil, feedRelease{URL: "http://x/a.tar.gz", SHA256: hash}, true},
+		{"缺少 url", nil, feedRelease{SHA256: hash}, false},
+		{"sha256 无效", nil, feedRelease{URL: "http://x/a.tar.gz", SHA256: "abc"}, false},
+		{"签名有效", public, feedRelease{URL: "http://x/a.tar.gz", SHA256: hash, Signature: signature}, true},
+		{"缺少签名", public, feedRelease{URL: "http://x/a.tar.gz", SHA256: hash}, false},
+		{"签名不匹配", public, feedRelease{URL: "http://x/a.tar.gz", SHA256: hash, Signature: other}, false},
+		{"签名不是 base64", public, feedRelease{URL: "http://x/a.tar.gz", SHA256: hash, Signature: "!"}, false},
+	}
+	for _, tt := range tests {
+		a := &feedAgent{key: tt.key}
+		if err := a.verify(&tt.release); (err == nil) != tt.ok {
+			t.Errorf("%s: verify = %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestFeedNextDelay(t *testing.T) {
+	a := &feedAgent{interval: 10 * time.Minute}
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for i, w := range want {
+		if got := a.nextDelay(errors.New("失败")); got != w {
+			t.Errorf("第 %d 次失败后等待 %v, want %v", i+1, got, w)
+		}
+	}
+	if got := a.nextDelay(nil); got != a.interval || a.status.Failures != 0 {
+		t.Errorf("成功后等待 %v，失败次数 %d", got, a.status.Failures)
+	}
+}
+
+func TestFeedCheckNow(t *testing.T) {
+	a := &feedAgent{trigger: make(chan struct{}, 1)}
+	// 多次触发合并为一次
+	a.checkNow()
+	a.checkNow()
+	if len(a.trigger) != 1 {
+		t.Errorf("触发 %d 次，应合并为 1 次", len(a.trigger))
+	}
+}
+
+func TestFeedAutoUpgrade(t *testing.T) {
+	setupTestEngine(t)
+	srv := newTestFeedServer(t)
+	srv.publish(t, "1.0.0", "")
+	srv.publish(t, "1.1.0", "")
+	srv.publish(t, "2.0.0-beta.1", "beta")
+
+	// 已部署版本未知时默认不升级
+	a, err := startFeed(&FeedConfig{URL: srv.URL + "/feed.json", Interval: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFeedCheck(t, a, time.Time{})
+	a.close()
+	status := a.snapshot()
+	if p := status.profile("default"); p == nil || p.State != feedSkipped || engine.Profile("").Deployed() != nil {
+		t.Fatalf("版本未知时不应升级: %+v", p)
+	}
+
+	a, err = startFeed(&FeedConfig{URL: srv.URL + "/feed.json", Interval: 3600, InstallUnknown: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.close()
+	waitFeedCheck(t, a, time.Time{})
+
+	status = a.snapshot()
+	p := status.profile("default")
+	if status.Error != "" || p == nil || p.State != feedUpgraded || p.Latest != "1.1.0" || p.Current != "1.1.0" {
+		t.Fatalf("status = %+v, profile = %+v", status, p)
+	}
+	if deployed := engine.Profile("").Deployed(); deployed == nil || deployed.Version != "1.1.0" {
+		t.Errorf("已部署 %+v", deployed)
+	}
+
+	// 已是最新版本时不再升级
+	a.checkNow()
+	waitFeedCheck(t, a, status.LastCheck)
+	status = a.snapshot()
+	if p := status.profile("default"); p.State != feedUpToDate {
+		t.Errorf("再次检查: %+v", p)
+	}
+
+	// 升级失败的发布不再重试，直到发布源出现新的发布
+	bad := feedRelease{Version: "1.2.0", URL: srv.URL + "/files/app-1.2.0.tar.gz", SHA256: sha256Hex([]byte("not a package"))}
+	srv.packages["app-1.2.0.tar.gz"] = []byte("not a package")
+	srv.feed.Releases = append(srv.feed.Releases, bad)
+	a.checkNow()
+	waitFeedCheck(t, a, status.LastCheck)
+	status = a.snapshot()
+	if p := status.profile("default"); p.State != feedFailed || p.Current != "1.1.0" {
+		t.Errorf("升级失败: %+v", p)
+	}
+	a.checkNow()
+	waitFeedCheck(t, a, status.LastCheck)
+	status = a.snapshot()
+	if p := status.profile("default"); p.State != feedFailed || !strings.Contains(p.Message, "等待新的发布") {
+		t.Errorf("失败的发布不应重试: %+v", p)
+	}
+}
+
+// 等待 LastCheck 晚于 after 的检查完成
+func waitFeedCheck(t *testing.T, a *feedAgent, after time.Time) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		status := a.snapshot()
+		if !status.Checking && status.LastCheck.After(after) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("等待检查超时")
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"linker-upgrader/upgrader"
+	"linker-upgrader/upgraderpb"
+)
+
+// gRPC 接口：与 HTTP 接口并列，同样只是升级引擎之上的一层
+type grpcServer struct {
+	upgraderpb.UnimplementedUpgraderServer
+}
+
+// gRPC 的 TLS 配置
+type GRPCTLSConfig struct {
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	ClientCAFile string `json:"client_ca_file"` // 可选，由该 CA 签发的客户端证书视为已认证
+}
+
+// 创建 gRPC 服务：按配置启用 TLS，所有调用先经过与 /api/ 相同的访问控制，再经过认证拦截器。
+// 没有配置任何认证方式时只允许监听本机回环地址或 unix socket
+func newGRPCServer(listeners []net.Listener, access *accessControl) (*grpc.Server, error) {
+	if !grpcAuthConfigured() {
+		for _, l := range listeners {
+			if !localListener(l) {
+				return nil, fmt.Errorf("gRPC 监听 %s 不限于本机，但未配置认证 (api_token 或 grpc_tls.client_ca_file)", l.Addr())
+			}
+		}
+	}
+
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(access.grpcUnary, grpcUnaryAuth),
+		grpc.ChainStreamInterceptor(access.grpcStream, grpcStreamAuth),
+	}
+	if appConfig.GRPCTLS != nil {
+		config, err := grpcTLSConfig(appConfig.GRPCTLS)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(config)))
+	}
+
+	server := grpc.NewServer(options...)
+	upgraderpb.RegisterUpgraderServer(server, &grpcServer{})
+	return server, nil
+}
+
+func grpcTLSConfig(c *GRPCTLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("加载 gRPC 证书失败: %v", err)
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 gRPC 客户端 CA 失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("gRPC 客户端 CA 无效: %s", c.ClientCAFile)
+		}
+		// 客户端证书可选，未提供证书的调用由拦截器按其他认证方式处理
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return config, nil
+}
+
+// 是否配置了 gRPC 认证方式：API 令牌或客户端证书
+func grpcAuthConfigured() bool {
+	return appConfig.APIToken != "" || (appConfig.GRPCTLS != nil && appConfig.GRPCTLS.ClientCAFile != "")
+}
+
+// 只能从本机访问的监听：回环地址或 unix socket
+func localListener(l net.Listener) bool {
+	switch addr := l.Addr().(type) {
+	case *net.TCPAddr:
+		return addr.IP.IsLoopback()
+	case *net.UnixAddr:
+		return true
+	}
+	return false
+}
+
+// 校验调用方：配置了认证方式时必须通过其中之一，未配置时只会监听本机地址。
+// req 为一元调用的请求，流式调用为 nil
+func grpcAuthorize(ctx context.Context, req any) error {
+	if !grpcAuthConfigured() {
+		return nil
+	}
+	// 与 HTTP 接口相同的 API 令牌：元数据 authorization: Bearer <api_token>
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, authorization := range md.Get("authorization") {
+			if validAPIToken(authorization) || grpcConfirmToken(req, authorization) {
+				return nil
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			return nil
+		}
+	}
+	log.Printf("gRPC 认证失败 (来自 %s)", remoteAddr(ctx))
+	return status.Error(codes.Unauthenticated, "未认证")
+}
+
+// gRPC 调用的访问控制：地址列表使用 api_allow/api_deny，Upload 计入上传频率，
+// 令牌错误的调用计入认证失败次数。req 为一元调用的请求，流式调用为 nil
+func (a *accessControl) grpcCheck(ctx context.Context, method string, req any) error {
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	addr := a.clientAddr(remote, md.Get("x-forwarded-for"))
+	if !a.api.permits(addr) {
+		log.Printf("拒绝访问: gRPC %s (来自 %s，不在允许范围内)", method, addr)
+		return status.Error(codes.PermissionDenied, "禁止访问")
+	}
+
+	if authorization := md.Get("authorization"); a.login != nil && len(authorization) > 0 {
+		if wait := a.login.wait(addr); wait > 0 {
+			log.Printf("拒绝认证: gRPC %s (来自 %s，认证失败次数过多)", method, addr)
+			return grpcTooManyRequests(wait)
+		}
+		valid := false
+		for _, value := range authorization {
+			valid = valid || validAPIToken(value) || grpcConfirmToken(req, value)
+		}
+		if !valid {
+			log.Printf("API 令牌认证失败: gRPC %s (来自 %s)", method, addr)
+			a.login.take(addr)
+		}
+	}
+
+	if a.upload != nil && method == upgraderpb.Upgrader_Upload_FullMethodName {
+		if wait := a.upload.take(addr); wait > 0 {
+			log.Printf("拒绝上传: gRPC %s (来自 %s，上传过于频繁)", method, addr)
+			return grpcTooManyRequests(wait)
+		}
+	}
+	return nil
+}
+
+func grpcTooManyRequests(wait time.Duration) error {
+	return status.Errorf(codes.ResourceExhausted, "请求过于频繁，请在 %d 秒后再试", int(math.Ceil(wait.Seconds())))
+}
+
+func (a *accessControl) grpcUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := a.grpcCheck(ctx, info.FullMethod, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *accessControl) grpcStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.grpcCheck(ss.Context(), info.FullMethod, nil); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// Confirm 调用是否携带所确认配置档的 confirm_token，与 /api/confirm 相同
+func grpcConfirmToken(req any, authorization string) bool {
+	confirm, ok := req.(*upgraderpb.ConfirmRequest)
+	if !ok {
+		return false
+	}
+	p := engine.Profile(confirm.Profile)
+	return p != nil && bearerMatches(authorization, p.ConfirmToken)
+}
+
+func grpcUnaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := grpcAuthorize(ctx, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func grpcStreamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := grpcAuthorize(ss.Context(), nil); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// 在所有监听上提供 gRPC 服务，任一监听出错时返回
+func serveGRPC(server *grpc.Server, listeners []net.Listener) error {
+	errCh := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() { errCh <- server.Serve(l) }()
+	}
+	return <-errCh
+}
+
+// 引擎错误对应的 gRPC 状态
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, upgrader.ErrUnknownProfile):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, upgrader.ErrBusy), errors.Is(err, upgrader.ErrPolicy), errors.Is(err, errUploadIncomplete):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, upgrader.ErrFileType), errors.Is(err, upgrader.ErrInvalidChannel), errors.Is(err, upgrader.ErrInvalidFilename):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, errUploadNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func remoteAddr(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// 上传升级包，保存到上传目录并返回 upload_id
+func (s *grpcServer) Upload(stream upgraderpb.Upgrader_UploadServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	info := first.GetInfo()
+	if info == nil {
+		return status.Error(codes.InvalidArgument, "第一条消息必须为 UploadInfo")
+	}
+	if sanitizeFilename(info.Filename) == "" {
+		return status.Error(codes.InvalidArgument, "文件名无效")
+	}
+
+	maxSize := appConfig.MaxFileSize << 20
+	upload, err := storeUpload(&chunkReader{stream: stream}, info.Filename, remoteAddr(stream.Context()), maxSize)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return status.Errorf(codes.ResourceExhausted, "文件大小超过限制 (%dMB)", appConfig.MaxFileSize)
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	log.Printf("gRPC 上传文件: %s (ID: %s), 大小: %d bytes", upload.Filename, upload.ID, upload.Size)
+	return stream.SendAndClose(&upgraderpb.UploadResponse{
+		UploadId: upload.ID,
+		Size:     upload.Size,
+		Sha256:   upload.SHA256,
+	})
+}
+
+// 将上传流中的文件分块作为 io.Reader 读取，客户端结束发送时返回 io.EOF
+type chunkReader struct {
+	stream upgraderpb.Upgrader_UploadServer
+	buf    []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		req, err := c.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		c.buf = req.GetChunk()
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
+// 执行升级并推送事件，升级本身的失败通过 finish 事件的结果返回；
+// 升级被拒绝时在 finish 事件之后返回对应的状态码
+func (s *grpcServer) Upgrade(req *upgraderpb.UpgradeRequest, stream upgraderpb.Upgrader_UpgradeServer) error {
+	upload, err := loadUpload(req.UploadId)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	var finish *upgraderpb.UpgradeEvent
+	onEvent := func(e upgrader.Event) {
+		event := eventToProto(e)
+		if e.Type == upgrader.EventFinish {
+			// 附带结果后在升级结束时发送
+			finish = event
+			return
+		}
+		// 客户端断开后继续升级，只是不再推送事件
+		stream.Send(event)
+	}
+
+	// 客户端断开连接不应中断进行中的升级
+	ctx := context.WithoutCancel(stream.Context())
+	result, err := engine.Upgrade(ctx, upload.upgradeRequest(req.Profile, req.Force, remoteAddr(stream.Context())), onEvent)
+	if finish == nil {
+		return grpcError(err)
+	}
+	finish.Result = resultToProto(result)
+	if sendErr := stream.Send(finish); sendErr != nil {
+		return sendErr
+	}
+	// 升级在执行前被拒绝（文件类型、版本策略）时，结果之外同时返回对应的状态码
+	if err != nil {
+		if st := status.Convert(grpcError(err)); st.Code() != codes.Internal {
+			return st.Err()
+		}
+	}
+	return nil
+}
+
+// 配置档状态
+func (s *grpcServer) Status(ctx context.Context, req *upgraderpb.StatusRequest) (*upgraderpb.StatusResponse, error) {
+	statuses, err := engine.Status(ctx, req.Profile)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &upgraderpb.StatusResponse{}
+	for _, st := range statuses {
+		ps := &upgraderpb.ProfileStatus{
+			Profile:     st.Profile,
+			TargetDir:   st.TargetDir,
+			ServiceName: st.ServiceName,
+			Upgrading:   st.Upgrading,
+			Deployed:    deployToProto(st.Deployed),
+			Channel:     st.Channel,
+		}
+		if st.Pending != nil {
+			ps.Pending = &upgraderpb.PendingUpgrade{
+				Version:    st.Pending.Version,
+				Filename:   st.Pending.Filename,
+				DeployedAt: timestamppb.New(st.Pending.DeployedAt),
+				Deadline:   timestamppb.New(st.Pending.Deadline),
+			}
+		}
+		if st.Service != nil {
+			ps.Service = &upgraderpb.ServiceStatus{Manager: st.Service.Manager, Active: st.Service.Active, Error: st.Service.Error}
+		}
+		resp.Profiles = append(resp.Profiles, ps)
+	}
+	return resp, nil
+}
+
+// 升级历史
+func (s *grpcServer) History(ctx context.Context, req *upgraderpb.HistoryRequest) (*upgraderpb.HistoryResponse, error) {
+	history, err := engine.History(req.Profile)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &upgraderpb.HistoryResponse{}
+	for _, h := range history {
+		resp.Entries = append(resp.Entries, &upgraderpb.HistoryEntry{
+			Time:        timestamppb.New(h.Time),
+			Filename:    h.Filename,
+			FromVersion: h.FromVersion,
+			ToVersion:   h.ToVersion,
+			Force:       h.Force,
+			Result:      h.Result,
+			Message:     h.Message,
+			Duration:    h.Duration,
+		})
+	}
+	return resp, nil
+}
+
+// 备份列表
+func (s *grpcServer) ListBackups(ctx context.Context, req *upgraderpb.ListBackupsRequest) (*upgraderpb.ListBackupsResponse, error) {
+	backups, err := engine.Backups(req.Profile)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &upgraderpb.ListBackupsResponse{}
+	for _, b := range backups {
+		resp.Backups = append(resp.Backups, &upgraderpb.Backup{Name: b.Name, Size: b.Size, Created: timestamppb.New(b.Created)})
+	}
+	return resp, nil
+}
+
+// 从备份恢复，恢复本身的失败通过结果返回
+func (s *grpcServer) Restore(ctx context.Context, req *upgraderpb.RestoreRequest) (*upgraderpb.UpgradeResult, error) {
+	result, err := engine.Restore(context.WithoutCancel(ctx), upgrader.RestoreRequest{
+		Profile: req.Profile,
+		Backup:  req.Backup,
+		Remote:  remoteAddr(ctx),
+	}, nil)
+	if errors.Is(err, upgrader.ErrUnknownProfile) || errors.Is(err, upgrader.ErrBusy) {
+		return nil, grpcError(err)
+	}
+	return resultToProto(result), nil
+}
+
+// 确认待确认的升级
+func (s *grpcServer) Confirm(ctx context.Context, req *upgraderpb.ConfirmRequest) (*upgraderpb.ConfirmResponse, error) {
+	err := engine.Confirm(req.Profile, req.Version, remoteAddr(ctx))
+	if errors.Is(err, upgrader.ErrUnknownProfile) {
+		return nil, grpcError(err)
+	}
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &upgraderpb.ConfirmResponse{Message: "升级已确认"}, nil
+}
+
+// 切换发布渠道，与 /api/channel 相同
+func (s *grpcServer) SetChannel(ctx context.Context, req *upgraderpb.SetChannelRequest) (*upgraderpb.SetChannelResponse, error) {
+	if err := engine.SetChannel(req.Profile, req.Channel, remoteAddr(ctx)); err != nil {
+		return nil, grpcError(err)
+	}
+	if autoUpdater != nil {
+		autoUpdater.checkNow()
+	}
+	return &upgraderpb.SetChannelResponse{Message: "发布渠道已切换为 " + req.Channel}, nil
+}
+
+func eventToProto(e upgrader.Event) *upgraderpb.UpgradeEvent {
+	return &upgraderpb.UpgradeEvent{
+		Time:     timestamppb.New(e.Time),
+		Profile:  e.Profile,
+		Type:     e.Type,
+		Step:     e.Step,
+		Status:   e.Status,
+		Message:  e.Message,
+		Logs:     e.Logs,
+		Duration: e.Duration,
+	}
+}
+
+func resultToProto(r *upgrader.UpgradeResult) *upgraderpb.UpgradeResult {
+	result := &upgraderpb.UpgradeResult{
+		Profile:     r.Profile,
+		Success:     r.Success,
+		Message:     r.Message,
+		Logs:        r.Logs,
+		ServiceLogs: r.ServiceLogs,
+		Deployed:    deployToProto(r.Deployed),
+	}
+	for _, step := range r.Steps {
+		result.Steps = append(result.Steps, &upgraderpb.StepResult{
+			Name:     step.Name,
+			Status:   step.Status,
+			Duration: step.Duration,
+			Message:  step.Message,
+		})
+	}
+	return result
+}
+
+func deployToProto(record *upgrader.DeployRecord) *upgraderpb.DeployRecord {
+	if record == nil {
+		return nil
+	}
+	return &upgraderpb.DeployRecord{
+		Version:       record.Version,
+		VersionSource: record.VersionSource,
+		Filename:      record.Filename,
+		Sha256:        record.SHA256,
+		DeployedAt:    timestamppb.New(record.DeployedAt),
+	}
+}
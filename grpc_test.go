@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"linker-upgrader/upgrader"
+	"linker-upgrader/upgraderpb"
+)
+
+func TestGRPCConfirm(t *testing.T) {
+	setupTestEngine(t)
+	appConfig.APIToken = "api"
+	appConfig.ConfirmToken = "confirm"
+	appConfig.ConfirmTimeout = 60
+	var err error
+	if engine, err = upgrader.New(appConfig.Config); err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	// confirm_token 只能用于 Confirm
+	confirm := &upgraderpb.ConfirmRequest{Profile: "default"}
+	tests := []struct {
+		name          string
+		authorization string
+		req           any
+		ok            bool
+	}{
+		{"API 令牌", "Bearer api", confirm, true},
+		{"confirm_token", "Bearer confirm", confirm, true},
+		{"confirm_token 用于其他调用", "Bearer confirm", &upgraderpb.StatusRequest{}, false},
+		{"confirm_token 用于流式调用", "Bearer confirm", nil, false},
+		{"令牌错误", "Bearer wrong", confirm, false},
+		{"未携带令牌", "", confirm, false},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.authorization))
+		}
+		err := grpcAuthorize(ctx, tt.req)
+		if (err == nil) != tt.ok || (err != nil && status.Code(err) != codes.Unauthenticated) {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+
+	s := &grpcServer{}
+	ctx := context.Background()
+	if _, err := s.Confirm(ctx, confirm); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("没有待确认的升级: %v", err)
+	}
+	if _, err := s.Confirm(ctx, &upgraderpb.ConfirmRequest{Profile: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("配置档不存在: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "app-1.0.0.tar.gz")
+	if err := os.WriteFile(path, testPackage(t, "1.0.0"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.Upgrade(ctx, upgrader.UpgradeRequest{FilePath: path, Filename: "app-1.0.0.tar.gz"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Confirm(ctx, &upgraderpb.ConfirmRequest{Profile: "default", Version: "1.1.0"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("版本不一致: %v", err)
+	}
+	if _, err := s.Confirm(ctx, &upgraderpb.ConfirmRequest{Profile: "default", Version: "1.0.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if pending := engine.Profile("").Pending(); pending != nil {
+		t.Errorf("确认后仍有待确认的升级: %+v", pending)
+	}
+}
+
+// 正确的 confirm_token 不计入认证失败次数
+func TestGRPCCheckConfirmToken(t *testing.T) {
+	setupTestEngine(t)
+	appConfig.APIToken = "api"
+	appConfig.ConfirmToken = "confirm"
+	var err error
+	if engine, err = upgrader.New(appConfig.Config); err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+	a, err := newAccessControl(AccessConfig{LoginRate: RateLimit{Requests: 1, Window: 60}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	confirm := &upgraderpb.ConfirmRequest{Profile: "default"}
+	call := func(token string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+token))
+		return a.grpcCheck(ctx, upgraderpb.Upgrader_Confirm_FullMethodName, confirm)
+	}
+	for i := 0; i < 3; i++ {
+		if err := call("confirm"); err != nil {
+			t.Fatalf("第 %d 次: %v", i+1, err)
+		}
+	}
+	call("wrong")
+	if err := call("confirm"); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("超过 login_rate: %v", err)
+	}
+}
+
+func TestGRPCSetChannel(t *testing.T) {
+	setupTestEngine(t)
+	s := &grpcServer{}
+	ctx := context.Background()
+	if _, err := s.SetChannel(ctx, &upgraderpb.SetChannelRequest{Profile: "default", Channel: "nightly"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("渠道无效: %v", err)
+	}
+	if _, err := s.SetChannel(ctx, &upgraderpb.SetChannelRequest{Profile: "missing", Channel: "beta"}); status.Code(err) != codes.NotFound {
+		t.Errorf("配置档不存在: %v", err)
+	}
+	if _, err := s.SetChannel(ctx, &upgraderpb.SetChannelRequest{Profile: "default", Channel: "beta"}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.Status(ctx, &upgraderpb.StatusRequest{Profile: "default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Profiles) != 1 || resp.Profiles[0].Channel != "beta" {
+		t.Errorf("Status = %+v", resp.Profiles)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// systemd 传递的第一个文件描述符
+const listenFDsStart = 3
+
+// 自更新时由旧进程启动的新进程，LISTEN_PID 无法预先确定，改为记录旧进程的 PID
+const envHandoverParent = "LINKER_UPGRADER_PARENT_PID"
+
+// 监听地址格式：
+//
+//	8080 / :8080            所有接口的 8080 端口
+//	192.168.1.10:8080       指定接口
+//	[::1]:8080              IPv6
+//	unix:/run/upgrader.sock Unix socket
+func parseListenAddr(addr string) (network, address string) {
+	addr = strings.TrimSpace(addr)
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return "unix", path
+	}
+	if _, err := strconv.Atoi(addr); err == nil {
+		return "tcp", ":" + addr
+	}
+	return "tcp", addr
+}
+
+// 监听地址对应的访问地址，用于启动日志
+func listenURL(l net.Listener) string {
+	if l.Addr().Network() == "unix" {
+		return "unix:" + l.Addr().String()
+	}
+	return "http://" + l.Addr().String()
+}
+
+// 监听所有地址，任一失败时关闭已打开的监听
+func listenAll(addrs []string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, addr := range addrs {
+		l, err := listen(addr)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+func listen(addr string) (net.Listener, error) {
+	network, address := parseListenAddr(addr)
+	if address == "" {
+		return nil, fmt.Errorf("监听地址无效: %q", addr)
+	}
+	if network == "unix" {
+		// 删除上次异常退出遗留的 socket 文件
+		if info, err := os.Lstat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+	}
+	l, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("监听 %s 失败: %v", addr, err)
+	}
+	return l, nil
+}
+
+// 关闭监听，Unix socket 文件随之删除
+func closeListeners(listeners []net.Listener) {
+	for _, l := range listeners {
+		l.Close()
+	}
+}
+
+// systemd socket 激活传入的监听，按 LISTEN_FDNAMES 中的名称分组
+// 未命名的监听名称为空；没有传入监听时返回 nil
+func inheritedListeners() (map[string][]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	inherited := err == nil && pid == os.Getpid()
+	if parent, err := strconv.Atoi(os.Getenv(envHandoverParent)); err == nil && parent == os.Getppid() {
+		inherited = true
+	}
+	os.Unsetenv(envHandoverParent)
+	if !inherited {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	// 避免传递给服务管理启动的子进程
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make(map[string][]net.Listener)
+	for i := 0; i < count; i++ {
+		fd := listenFDsStart + i
+		syscall.CloseOnExec(fd)
+		name := ""
+		if i < len(names) && names[i] != "unknown" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(fd), "listen-fd-"+strconv.Itoa(fd))
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("文件描述符 %d 不是监听 socket: %v", fd, err)
+		}
+		listeners[name] = append(listeners[name], l)
+	}
+	return listeners, nil
+}
+
+// 向 systemd 发送状态通知（Type=notify），未设置 NOTIFY_SOCKET 时忽略
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		// 抽象命名空间
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// 监听对应的文件，用于传递给新进程
+func listenerFile(l net.Listener) (*os.File, error) {
+	switch l := l.(type) {
+	case *net.TCPListener:
+		return l.File()
+	case *net.UnixListener:
+		return l.File()
+	}
+	return nil, fmt.Errorf("不支持传递的监听: %s", l.Addr())
+}
+
+// exec 传递文件时会把共享的文件描述符设为阻塞模式，使 Accept 无法被 Close 中断，
+// 启动新进程后需要恢复为非阻塞模式
+func restoreNonblock(l net.Listener) {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return
+	}
+	raw.Control(func(fd uintptr) {
+		if err := syscall.SetNonblock(int(fd), true); err != nil {
+			log.Printf("恢复监听 %s 为非阻塞模式失败: %v", l.Addr(), err)
+		}
+	})
+}
@@ -3,79 +3,109 @@ package main
 import (
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"html/template"
-	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	"google.golang.org/grpc"
+
+	"linker-upgrader/upgrader"
 )
 
 //go:embed .github/banner.jpg
 var bannerFS embed.FS
 
-// 配置结构体
+// 配置结构体：升级引擎配置加上 HTTP 服务与界面配置
 type Config struct {
-	// 目录配置
+	upgrader.Config
+
+	// 上传目录
 	UploadDir string `json:"upload_dir"`
-	TargetDir string `json:"target_dir"`
-	BackupDir string `json:"backup_dir"`
 
-	// 服务配置
-	ServiceName string `json:"service_name"`
-	Port        string `json:"port"`
-	MaxFileSize int64  `json:"max_file_size"` // 单位：MB
+	Port        string         `json:"port"`
+	Listen      []string       `json:"listen,omitempty"` // 监听地址列表，配置后代替 port
+	GRPCPort    string         `json:"grpc_port"`        // gRPC 监听地址，为空时不启用
+	GRPCTLS     *GRPCTLSConfig `json:"grpc_tls,omitempty"`
+	MaxFileSize int64          `json:"max_file_size"` // 单位：MB
 
-	// 功能开关
-	EnableBackup    bool `json:"enable_backup"`
-	EnableService   bool `json:"enable_service"`
+	// 上传文件清理
 	EnableCleanup   bool `json:"enable_cleanup"`
 	CleanupInterval int  `json:"cleanup_interval"` // 小时
 	FileMaxAge      int  `json:"file_max_age"`     // 小时
 
-	// 权限配置
-	DirPermission  string `json:"dir_permission"`
-	FilePermission string `json:"file_permission"`
-	ExecPermission string `json:"exec_permission"`
+	// API 令牌：请求头 Authorization: Bearer <api_token> 认证的请求免于 CSRF 校验
+	APIToken string `json:"api_token"`
+
+	// 地址访问控制与频率限制
+	Access AccessConfig `json:"access"`
+
+	// 从 URL 下载升级包
+	Download DownloadConfig `json:"download"`
+
+	// MQTT 客户端模式，未配置时不启用
+	MQTT *MQTTConfig `json:"mqtt,omitempty"`
+
+	// 发布源自动升级，未配置时不启用
+	Feed *FeedConfig `json:"feed,omitempty"`
+
+	// 升级本程序
+	SelfUpdate SelfUpdateConfig `json:"self_update"`
 
 	// 界面配置
-	Title       string   `json:"title"`
-	Description string   `json:"description"`
-	AcceptTypes []string `json:"accept_types"`
+	Title string `json:"title"`
 }
 
 // 默认配置
 func getDefaultConfig() *Config {
 	return &Config{
+		Config:          upgrader.DefaultConfig(),
 		UploadDir:       "./uploads",
-		TargetDir:       "/opt/myapp",
-		BackupDir:       "/opt/myapp/backup",
-		ServiceName:     "myapp",
 		Port:            ":8080",
 		MaxFileSize:     100, // MB
-		EnableBackup:    true,
-		EnableService:   true,
 		EnableCleanup:   true,
 		CleanupInterval: 1,  // 1 小时
 		FileMaxAge:      24, // 24 小时
-		DirPermission:   "0755",
-		FilePermission:  "0644",
-		ExecPermission:  "0755",
 		Title:           "🚀 灵心巧手 - 上位机程序升级",
-		Description:     "支持 .tar.gz, .zip, 可执行文件的程序升级系统",
-		AcceptTypes:     []string{".tar.gz", ".zip", ".gz", "application/x-executable", "application/octet-stream"},
+		Access: AccessConfig{
+			UploadRate: RateLimit{Requests: 10, Window: 60},
+			LoginRate:  RateLimit{Requests: 5, Window: 300},
+		},
+		Download:   DownloadConfig{Timeout: 1800, Retries: 5},
+		SelfUpdate: SelfUpdateConfig{ReadyTimeout: 30},
 	}
 }
 
+// 程序版本，发布时由 -ldflags "-X main.VERSION=..." 设置
+var VERSION = "dev"
+
 // 全局配置实例
 var appConfig *Config
 
+// 升级引擎
+var engine *upgrader.Engine
+
+// 访问控制
+var access *accessControl
+
+// 监听、HTTP 服务与 MQTT 客户端，自更新时交给新进程或重新启动
+var (
+	httpListeners []net.Listener
+	grpcListeners []net.Listener
+	httpServer    *http.Server
+	mqttClient    *mqttAgent
+)
+
 type UpgradeHandler struct{}
 
 // 增强的HTML模板，支持拖拽上传
@@ -274,6 +304,77 @@ const htmlTemplate = `
             overflow-y: auto; 
             font-size: 12px;
         }
+        .profile-select {
+            margin-bottom: 15px;
+        }
+        .profile-select select {
+            padding: 6px;
+            font-size: 14px;
+        }
+        .history {
+            margin-top: 20px;
+            font-size: 12px;
+        }
+        .history table {
+            width: 100%;
+            border-collapse: collapse;
+            margin-top: 5px;
+        }
+        .history th, .history td {
+            border-bottom: 1px solid #dee2e6;
+            padding: 4px;
+            text-align: left;
+        }
+        .history .result-success { color: #155724; }
+        .history .result-failed, .history .result-rejected { color: #721c24; }
+        .service-form {
+            margin-bottom: 15px;
+        }
+        .service-btn {
+            background: #6c757d;
+            color: white;
+            border: none;
+            padding: 8px 16px;
+            border-radius: 4px;
+            cursor: pointer;
+            width: 100%;
+        }
+        .service-btn:hover {
+            background: #5a6268;
+        }
+        .channel-form {
+            display: flex;
+            gap: 10px;
+            align-items: center;
+        }
+        .channel-form label {
+            margin: 0;
+            white-space: nowrap;
+        }
+        .channel-form select {
+            flex: 1;
+            padding: 6px;
+        }
+        .channel-form .service-btn {
+            width: auto;
+        }
+        .url-form {
+            border-top: 1px solid #dee2e6;
+            padding-top: 10px;
+        }
+        .url-form input[type="url"], .url-form input[type="text"], .url-form input[type="password"] {
+            width: 100%;
+            padding: 8px;
+            margin-top: 5px;
+            border: 1px solid #ccc;
+            border-radius: 4px;
+            box-sizing: border-box;
+        }
+        .force-option {
+            font-weight: normal;
+            font-size: 14px;
+            color: #666;
+        }
         .config { 
             background: #fff3cd; 
             border: 1px solid #ffeaa7; 
@@ -319,8 +420,23 @@ const htmlTemplate = `
 
         <h1>{{.Config.Title}}</h1>
 
+        {{if gt (len .Profiles) 1}}
+        <form class="profile-select" action="/" method="get">
+            <label for="profileSelect">程序配置档:</label>
+            <select name="profile" id="profileSelect" onchange="this.form.submit()">
+                {{range .Profiles}}
+                <option value="{{.Name}}" {{if eq .Name $.Profile.Name}}selected{{end}}>{{.Title}}</option>
+                {{end}}
+            </select>
+        </form>
+        {{end}}
+
         <div class="config">
-            <strong>当前配置:</strong> 目标目录：{{.Config.TargetDir}} | 服务：{{.Config.ServiceName}} | 最大文件：{{.Config.MaxFileSize}}MB
+            <strong>当前配置:</strong> 配置档：{{.Profile.Title}} | 目标目录：{{.Profile.TargetDir}} | 服务：{{.Profile.ServiceName}} ({{.Profile.ServiceManager}}) | 最大文件：{{.Config.MaxFileSize}}MB
+            <br><strong>当前版本:</strong> {{if .Deployed}}{{if .Deployed.Version}}{{.Deployed.Version}}{{else}}未知{{end}} | 部署时间：{{.Deployed.DeployedAt.Format "2006-01-02 15:04:05"}} | SHA256：{{printf "%.12s" .Deployed.SHA256}}{{else}}尚无部署记录{{end}}
+            {{with .Pending}}<br><strong>⏳ 等待应用确认:</strong> {{.Version}}，截止 {{.Deadline.Format "2006-01-02 15:04:05"}}，超时未确认将自动恢复{{end}}
+            {{with .Feed}}<br><strong>自动升级:</strong> 渠道：{{$.Profile.ActiveChannel}} | 上次检查：{{if .LastCheck.IsZero}}尚未检查{{else}}{{.LastCheck.Format "2006-01-02 15:04:05"}}{{end}} | 下次检查：{{if .Checking}}正在检查{{else}}{{.NextCheck.Format "2006-01-02 15:04:05"}}{{end}}{{if .Error}} | 错误：{{.Error}}{{end}}{{end}}
+            {{with .FeedProfile}}<br><strong>最新发布:</strong> {{if .Latest}}{{.Latest}}{{else}}无{{end}} | 状态：{{.State}}{{if .Message}} ({{.Message}}){{end}}{{end}}
         </div>
 
         {{if .Message}}
@@ -334,15 +450,18 @@ const htmlTemplate = `
         {{end}}
 
         <form class="upload-form" enctype="multipart/form-data" action="/upload" method="post" id="uploadForm">
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            <input type="hidden" name="profile" value="{{.Profile.Name}}">
+            <input type="hidden" name="upload_id" id="uploadId">
             <div class="form-group">
-                <label>选择程序文件 ({{.Config.Description}}):</label>
+                <label>选择程序文件 ({{.Profile.Description}}):</label>
 
                 <!-- 拖拽上传区域 -->
                 <div class="drag-drop-area" id="dragDropArea">
                     <div class="drag-drop-content">
                         <div class="drag-drop-icon">📁</div>
                         <div class="drag-drop-text">拖拽文件到此处或点击选择</div>
-                        <div class="drag-drop-hint">支持 {{.Config.Description}}</div>
+                        <div class="drag-drop-hint">支持 {{.Profile.Description}}</div>
                     </div>
                 </div>
 
@@ -364,19 +483,106 @@ const htmlTemplate = `
                 </div>
             </div>
 
+            <div class="form-group">
+                <label class="force-option"><input type="checkbox" name="force" value="true"> 强制升级（忽略降级/重复安装策略，将记录审计日志）</label>
+            </div>
+
             <div class="form-group">
                 <input type="submit" value="🚀 上传并升级程序" id="submitBtn">
             </div>
         </form>
 
+        <form class="upload-form url-form" enctype="multipart/form-data" action="/upload" method="post" id="urlForm">
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            <input type="hidden" name="profile" value="{{.Profile.Name}}">
+            <div class="form-group">
+                <label for="urlInput">或从 URL 下载升级包（如内部制品服务器）:</label>
+                <input type="url" name="url" id="urlInput" placeholder="https://artifacts.example.com/app-1.2.0.tar.gz" required>
+                <input type="text" name="sha256" placeholder="SHA256（可选，下载后校验）" pattern="[0-9a-fA-F]{64}">
+                <input type="password" name="auth" placeholder="Authorization 请求头（可选），如 Bearer xxxx" autocomplete="off">
+            </div>
+            <div class="form-group">
+                <label class="force-option"><input type="checkbox" name="force" value="true"> 强制升级（忽略降级/重复安装策略，将记录审计日志）</label>
+            </div>
+            <div class="form-group">
+                <input type="submit" value="🌐 下载并升级程序" id="urlSubmitBtn">
+            </div>
+        </form>
+
+        {{if .Profile.ServiceEnabled}}
+        <form class="service-form" action="/service" method="post">
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            <input type="hidden" name="profile" value="{{.Profile.Name}}">
+            <input type="hidden" name="action" value="restart">
+            <button type="submit" class="service-btn">🔄 重启服务 ({{.Profile.ServiceName}})</button>
+        </form>
+        {{end}}
+
+        {{if .Feed}}
+        <form class="service-form channel-form" action="/channel" method="post">
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            <input type="hidden" name="profile" value="{{.Profile.Name}}">
+            <label>发布渠道:</label>
+            <select name="channel">
+                {{range .Profile.Channels}}
+                <option value="{{.}}" {{if eq . $.Profile.ActiveChannel}}selected{{end}}>{{.}}</option>
+                {{end}}
+            </select>
+            <button type="submit" class="service-btn">切换渠道</button>
+        </form>
+        <form class="service-form" action="/feed" method="post">
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            <input type="hidden" name="profile" value="{{.Profile.Name}}">
+            <button type="submit" class="service-btn">🔍 立即检查更新</button>
+        </form>
+        {{end}}
+
+        {{if .SelfUpdateBlocked}}
+        <div class="info">{{.SelfUpdateBlocked}}</div>
+        {{else}}
+        <form class="upload-form url-form" enctype="multipart/form-data" action="/self-update" method="post">
+            <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            <div class="form-group">
+                <label>升级本程序（当前版本 {{.Version}}）:</label>
+                <input type="file" name="file" required>
+            </div>
+            <div class="form-group">
+                <label>SHA256（可选）:</label>
+                <input type="text" name="sha256" placeholder="新程序的 SHA256 校验和">
+            </div>
+            {{if .Config.SelfUpdate.PublicKey}}
+            <div class="form-group">
+                <label>签名:</label>
+                <input type="text" name="signature" placeholder="对 SHA256 摘要的 ed25519 签名（base64）" required>
+            </div>
+            {{end}}
+            <div class="form-group">
+                <input type="submit" value="⬆️ 升级本程序">
+            </div>
+        </form>
+        {{end}}
+
         <div class="info">
             <strong>升级流程说明:</strong><br>
-            {{if .Config.EnableService}}1. 停止当前服务 ({{.Config.ServiceName}})<br>{{end}}
-            {{if .Config.EnableBackup}}2. 备份现有程序到 {{.Config.BackupDir}}<br>{{end}}
-            3. 部署新程序到 {{.Config.TargetDir}}<br>
-            4. 设置权限 (目录:{{.Config.DirPermission}}, 文件:{{.Config.FilePermission}}, 可执行:{{.Config.ExecPermission}})<br>
-            {{if .Config.EnableService}}5. 启动服务并验证状态<br>{{end}}
+            {{range .Flow}}{{.Title}}<br>{{range .Details}}&nbsp;&nbsp;• {{.}}<br>{{end}}{{end}}
+        </div>
+
+        {{if .History}}
+        <div class="history">
+            <strong>升级历史:</strong>
+            <table>
+                <tr><th>时间</th><th>文件</th><th>版本</th><th>结果</th></tr>
+                {{range .History}}
+                <tr>
+                    <td>{{.Time.Format "2006-01-02 15:04:05"}}</td>
+                    <td>{{.Filename}}</td>
+                    <td>{{.FromVersion}} → {{.ToVersion}}{{if .Force}} (强制){{end}}</td>
+                    <td class="result-{{.Result}}" title="{{.Message}}">{{.Result}}</td>
+                </tr>
+                {{end}}
+            </table>
         </div>
+        {{end}}
     </div>
 
     <script>
@@ -458,7 +664,7 @@ const htmlTemplate = `
                 }) || file.name.toLowerCase().includes('.tar.gz');
 
                 if (!isAccepted) {
-                    alert('不支持的文件类型。请选择：{{.Config.Description}}');
+                    alert('不支持的文件类型。请选择：{{.Profile.Description}}');
                     return;
                 }
 
@@ -483,7 +689,7 @@ const htmlTemplate = `
                 } else {
                     icon.textContent = '📁';
                     text.textContent = '拖拽文件到此处或点击选择';
-                    hint.textContent = '支持 {{.Config.Description}}';
+                    hint.textContent = '支持 {{.Profile.Description}}';
                 }
             }
 
@@ -496,34 +702,142 @@ const htmlTemplate = `
                 return parseFloat((bytes / Math.pow(k, i)).toFixed(2)) + ' ' + sizes[i];
             }
 
+            // 断点续传：文件按块上传到 /uploads，网络中断后从服务器已接收的位置继续，
+            // 完成后以 upload_id 提交表单执行升级
+            const chunkSize = 1024 * 1024;
+            const maxRetries = 10;
+            const csrfToken = '{{.CSRFToken}}';
+            const uploadIdInput = document.getElementById('uploadId');
+
+            function sleep(ms) {
+                return new Promise(function(resolve) { setTimeout(resolve, ms); });
+            }
+
+            function tusRequest(method, url, headers, body) {
+                return fetch(url, {
+                    method: method,
+                    headers: Object.assign({'Tus-Resumable': '1.0.0', 'X-CSRF-Token': csrfToken}, headers || {}),
+                    body: body,
+                    credentials: 'same-origin'
+                });
+            }
+
+            // 同一文件在页面刷新后继续之前的上传
+            function uploadKey(file) {
+                return 'upload:' + file.name + ':' + file.size + ':' + file.lastModified;
+            }
+
+            // 创建上传或继续已有的上传，返回上传地址和服务器已接收的字节数
+            async function openUpload(file) {
+                const saved = localStorage.getItem(uploadKey(file));
+                if (saved) {
+                    const resp = await tusRequest('HEAD', saved);
+                    if (resp.ok) {
+                        return {url: saved, offset: parseInt(resp.headers.get('Upload-Offset'), 10)};
+                    }
+                    localStorage.removeItem(uploadKey(file));
+                }
+                const resp = await tusRequest('POST', '/uploads', {
+                    'Upload-Length': String(file.size),
+                    'Upload-Metadata': 'filename ' + btoa(unescape(encodeURIComponent(file.name)))
+                });
+                if (resp.status !== 201) {
+                    throw new Error(await resp.text());
+                }
+                const url = resp.headers.get('Location');
+                localStorage.setItem(uploadKey(file), url);
+                return {url: url, offset: 0};
+            }
+
+            async function resumableUpload(file) {
+                const upload = await openUpload(file);
+                let offset = upload.offset;
+                let failures = 0;
+                while (offset < file.size) {
+                    const percent = Math.floor(offset * 100 / file.size);
+                    progressBar.style.width = percent + '%';
+                    submitBtn.value = '🔄 正在上传 ' + percent + '%';
+
+                    let resp = null;
+                    try {
+                        resp = await tusRequest('PATCH', upload.url, {
+                            'Content-Type': 'application/offset+octet-stream',
+                            'Upload-Offset': String(offset)
+                        }, file.slice(offset, offset + chunkSize));
+                    } catch (e) {
+                        // 网络错误，查询进度后重试
+                    }
+                    if (resp && resp.status === 204) {
+                        offset = parseInt(resp.headers.get('Upload-Offset'), 10);
+                        failures = 0;
+                        continue;
+                    }
+                    if (resp && resp.status !== 409 && resp.status < 500) {
+                        throw new Error(await resp.text());
+                    }
+
+                    failures++;
+                    if (failures > maxRetries) {
+                        throw new Error('网络连接失败，已上传的部分会保留，请稍后重新上传同一文件');
+                    }
+                    submitBtn.value = '🔄 网络中断，正在重试 (' + failures + '/' + maxRetries + ')';
+                    await sleep(Math.min(30000, 1000 * Math.pow(2, failures)));
+
+                    let head = null;
+                    try {
+                        head = await tusRequest('HEAD', upload.url);
+                    } catch (e) {
+                        continue;
+                    }
+                    if (head.ok) {
+                        offset = parseInt(head.headers.get('Upload-Offset'), 10);
+                    } else if (head.status === 404) {
+                        localStorage.removeItem(uploadKey(file));
+                        throw new Error('上传已失效，请重新上传');
+                    }
+                }
+                progressBar.style.width = '100%';
+                localStorage.removeItem(uploadKey(file));
+                return upload.url.split('/').pop();
+            }
+
             // 表单提交处理
             uploadForm.addEventListener('submit', function(e) {
-                if (!fileInput.files[0]) {
+                const file = fileInput.files[0];
+                if (!file) {
                     e.preventDefault();
                     alert('请先选择要上传的文件');
                     return;
                 }
+                // 浏览器不支持时使用普通表单上传
+                if (!window.fetch || !window.localStorage) {
+                    return;
+                }
+                e.preventDefault();
 
-                // 禁用提交按钮
+                // 禁用提交按钮并显示进度条
                 submitBtn.disabled = true;
                 submitBtn.value = '🔄 正在上传...';
-
-                // 显示进度条
                 uploadProgress.style.display = 'block';
 
-                // 模拟进度条（实际项目中应该使用XMLHttpRequest来获取真实进度）
-                let progress = 0;
-                const progressInterval = setInterval(function() {
-                    progress += Math.random() * 15;
-                    if (progress > 90) progress = 90;
-                    progressBar.style.width = progress + '%';
-                }, 200);
-
-                // 表单提交后清理
-                setTimeout(function() {
-                    clearInterval(progressInterval);
-                    progressBar.style.width = '100%';
-                }, 1000);
+                resumableUpload(file).then(function(id) {
+                    uploadIdInput.value = id;
+                    fileInput.disabled = true;
+                    submitBtn.value = '🔄 正在升级...';
+                    uploadForm.submit();
+                }).catch(function(err) {
+                    alert('上传失败：' + err.message);
+                    submitBtn.disabled = false;
+                    submitBtn.value = '🚀 上传并升级程序';
+                    uploadProgress.style.display = 'none';
+                });
+            });
+
+            // 下载过程中禁用提交按钮
+            document.getElementById('urlForm').addEventListener('submit', function() {
+                const btn = document.getElementById('urlSubmitBtn');
+                btn.disabled = true;
+                btn.value = '🔄 正在下载并升级...';
             });
 
             // 防止整个页面的拖拽默认行为
@@ -542,10 +856,22 @@ const htmlTemplate = `
 
 type PageData struct {
 	Config         *Config
+	Profile        *upgrader.Profile
+	Profiles       []*upgrader.Profile
 	Message        string
 	MessageType    string
 	Logs           string
 	AcceptTypesStr string
+	Deployed       *upgrader.DeployRecord
+	Pending        *upgrader.PendingUpgrade
+	Flow           []upgrader.FlowItem
+	History        []upgrader.HistoryEntry
+	CSRFToken      string
+	Version        string
+	// 不能升级本程序的原因，为空时显示升级表单
+	SelfUpdateBlocked string
+	Feed              *feedStatus
+	FeedProfile       *feedProfileStatus
 }
 
 // Banner图片处理器
@@ -568,283 +894,94 @@ func bannerHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *UpgradeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	tmpl := template.Must(template.New("upload").Parse(htmlTemplate))
-	data := PageData{
-		Config:         appConfig,
-		AcceptTypesStr: strings.Join(appConfig.AcceptTypes, ","),
-	}
-	tmpl.Execute(w, data)
-}
-
-func uploadHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Redirect(w, r, "/", http.StatusSeeOther)
-		return
-	}
-
-	// 使用配置中的文件大小限制
-	maxSize := appConfig.MaxFileSize << 20 // MB to bytes
-	r.ParseMultipartForm(maxSize)
-
-	file, handler, err := r.FormFile("file")
-	if err != nil {
-		showResult(w, "上传失败："+err.Error(), "error", "")
-		return
-	}
-	defer file.Close()
-
-	log.Printf("开始上传文件: %s, 大小: %d bytes", handler.Filename, handler.Size)
-
-	// 创建上传目录
-	if err := os.MkdirAll(appConfig.UploadDir, getPermission(appConfig.DirPermission)); err != nil {
-		showResult(w, "创建上传目录失败："+err.Error(), "error", "")
+	profile := engine.Profile(r.URL.Query().Get("profile"))
+	if profile == nil {
+		http.NotFound(w, r)
 		return
 	}
+	showResult(w, r, http.StatusOK, profile, "", "", "")
+}
 
-	// 保存上传的文件
-	uploadPath := filepath.Join(appConfig.UploadDir, handler.Filename)
-	dst, err := os.Create(uploadPath)
+// 状态 API：返回配置档摘要和已部署版本，未指定 profile 时返回全部配置档
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("profile")
+	statuses, err := engine.Status(r.Context(), name)
 	if err != nil {
-		showResult(w, "创建文件失败："+err.Error(), "error", "")
+		writeEngineError(w, err)
 		return
 	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, file); err != nil {
-		showResult(w, "保存文件失败："+err.Error(), "error", "")
+	if name != "" {
+		writeJSON(w, http.StatusOK, statuses[0])
 		return
 	}
+	writeJSON(w, http.StatusOK, statuses)
+}
 
-	// 执行升级
-	logs, err := performUpgrade(uploadPath, handler.Filename)
+// 升级历史 API
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	history, err := engine.History(r.URL.Query().Get("profile"))
 	if err != nil {
-		showResult(w, "升级失败："+err.Error(), "error", logs)
+		writeEngineError(w, err)
 		return
 	}
-
-	showResult(w, "程序升级成功！", "success", logs)
-}
-
-func performUpgrade(filePath, filename string) (string, error) {
-	var logs strings.Builder
-
-	logs.WriteString(fmt.Sprintf("开始升级程序: %s\n", filename))
-	logs.WriteString(fmt.Sprintf("时间: %s\n", time.Now().Format("2006-01-02 15:04:05")))
-	logs.WriteString(fmt.Sprintf("配置: 目标=%s, 服务=%s\n\n", appConfig.TargetDir, appConfig.ServiceName))
-
-	step := 1
-
-	// 1. 停止服务（可选）
-	if appConfig.EnableService {
-		logs.WriteString(fmt.Sprintf("%d. 停止当前服务 (%s)...\n", step, appConfig.ServiceName))
-		if err := runCommand("systemctl", "stop", appConfig.ServiceName); err != nil {
-			logs.WriteString(fmt.Sprintf("   警告: 停止服务失败 (可能服务不存在): %v\n", err))
-		} else {
-			logs.WriteString("   ✓ 服务已停止\n")
-		}
-		step++
-	}
-
-	// 2. 创建必要目录
-	logs.WriteString(fmt.Sprintf("\n%d. 创建必要目录...\n", step))
-	dirs := []string{appConfig.TargetDir}
-	if appConfig.EnableBackup {
-		dirs = append(dirs, appConfig.BackupDir)
-	}
-
-	dirPerm := getPermission(appConfig.DirPermission)
-	for _, dir := range dirs {
-		if err := os.MkdirAll(dir, dirPerm); err != nil {
-			return logs.String(), fmt.Errorf("创建目录 %s 失败: %v", dir, err)
-		}
-		logs.WriteString(fmt.Sprintf("   ✓ 目录 %s 已准备 (权限:%s)\n", dir, appConfig.DirPermission))
+	if history == nil {
+		history = []upgrader.HistoryEntry{}
 	}
-	step++
-
-	// 3. 备份现有程序（可选）
-	if appConfig.EnableBackup {
-		logs.WriteString(fmt.Sprintf("\n%d. 备份现有程序...\n", step))
-		backupPath := filepath.Join(appConfig.BackupDir, fmt.Sprintf("backup_%s.tar.gz", time.Now().Format("20060102_150405")))
-		if err := runCommand("tar", "-czf", backupPath, "-C", appConfig.TargetDir, "."); err != nil {
-			logs.WriteString(fmt.Sprintf("   警告: 备份失败 (可能没有现有程序): %v\n", err))
-		} else {
-			logs.WriteString(fmt.Sprintf("   ✓ 备份已保存到: %s\n", backupPath))
-		}
-		step++
-	}
-
-	// 4. 部署新程序
-	logs.WriteString(fmt.Sprintf("\n%d. 部署新程序...\n", step))
-	if err := deployProgram(filePath, filename, &logs); err != nil {
-		return logs.String(), err
-	}
-	step++
-
-	// 5. 设置权限
-	logs.WriteString(fmt.Sprintf("\n%d. 设置程序权限...\n", step))
-	if err := setPermissions(appConfig.TargetDir, &logs); err != nil {
-		return logs.String(), err
-	}
-	step++
-
-	// 6. 启动服务（可选）
-	if appConfig.EnableService {
-		logs.WriteString(fmt.Sprintf("\n%d. 启动服务 (%s)...\n", step, appConfig.ServiceName))
-		if err := runCommand("systemctl", "start", appConfig.ServiceName); err != nil {
-			logs.WriteString(fmt.Sprintf("   警告: 启动服务失败: %v\n", err))
-			logs.WriteString("   请手动启动程序或检查服务配置\n")
-		} else {
-			logs.WriteString("   ✓ 服务已启动\n")
-
-			// 等待一下再检查状态
-			time.Sleep(2 * time.Second)
-			if err := runCommand("systemctl", "is-active", appConfig.ServiceName); err != nil {
-				logs.WriteString("   警告：服务状态检查失败，请手动验证\n")
-			} else {
-				logs.WriteString("   ✓ 服务运行正常\n")
-			}
-		}
-	}
-
-	logs.WriteString(fmt.Sprintf("\n升级完成时间: %s\n", time.Now().Format("2006-01-02 15:04:05")))
-	return logs.String(), nil
+	writeJSON(w, http.StatusOK, history)
 }
 
-func deployProgram(filePath, filename string, logs *strings.Builder) error {
-	ext := strings.ToLower(filepath.Ext(filename))
-
-	switch ext {
-	case ".gz":
-		if strings.HasSuffix(strings.ToLower(filename), ".tar.gz") {
-			// tar.gz 文件
-			logs.WriteString("   解压 tar.gz 文件...\n")
-			if err := runCommand("tar", "-xzf", filePath, "-C", appConfig.TargetDir); err != nil {
-				return fmt.Errorf("解压 tar.gz 失败: %v", err)
-			}
-		} else {
-			// 单个 .gz 文件
-			logs.WriteString("   解压 gz 文件...\n")
-			outputPath := filepath.Join(appConfig.TargetDir, strings.TrimSuffix(filename, ".gz"))
-			cmd := exec.Command("sh", "-c", fmt.Sprintf("gunzip -c %s > %s", filePath, outputPath))
-			if err := cmd.Run(); err != nil {
-				return fmt.Errorf("解压 gz 文件失败: %v", err)
-			}
-		}
-	case ".zip":
-		logs.WriteString("   解压 zip 文件...\n")
-		if err := runCommand("unzip", "-o", filePath, "-d", appConfig.TargetDir); err != nil {
-			return fmt.Errorf("解压 zip 失败: %v", err)
-		}
-	default:
-		// 直接复制可执行文件
-		logs.WriteString("   复制可执行文件...\n")
-		targetPath := filepath.Join(appConfig.TargetDir, filename)
-		if err := copyFile(filePath, targetPath); err != nil {
-			return fmt.Errorf("复制文件失败: %v", err)
-		}
-	}
-
-	logs.WriteString("   ✓ 程序部署完成\n")
-	return nil
-}
-
-func setPermissions(targetDir string, logs *strings.Builder) error {
-	dirPerm := getPermission(appConfig.DirPermission)
-	filePerm := getPermission(appConfig.FilePermission)
-	execPerm := getPermission(appConfig.ExecPermission)
-
-	// 遍历目录，为可执行文件设置权限
-	err := filepath.Walk(targetDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// 为所有文件设置适当权限
-		if info.IsDir() {
-			os.Chmod(path, dirPerm)
-		} else {
-			// 检查是否为可执行文件
-			if isExecutable(path) {
-				os.Chmod(path, execPerm)
-				logs.WriteString(fmt.Sprintf("   ✓ 设置可执行权限 (%s): %s\n", appConfig.ExecPermission, path))
-			} else {
-				os.Chmod(path, filePerm)
-			}
-		}
-		return nil
-	})
-
-	if err != nil {
-		return fmt.Errorf("设置权限失败: %v", err)
-	}
-
-	return nil
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
 }
 
-func isExecutable(filePath string) bool {
-	// 检查文件是否为可执行文件
-	file, err := os.Open(filePath)
-	if err != nil {
-		return false
-	}
-	defer file.Close()
-
-	// 读取文件头部判断是否为 ELF 文件
-	header := make([]byte, 4)
-	if _, err := file.Read(header); err != nil {
-		return false
-	}
-
-	// ELF 魔数：0x7F 'E' 'L' 'F'
-	if len(header) >= 4 && header[0] == 0x7F && header[1] == 'E' && header[2] == 'L' && header[3] == 'F' {
-		return true
-	}
-
-	// 也可以检查文件扩展名
-	ext := strings.ToLower(filepath.Ext(filePath))
-	return ext == "" || ext == ".bin" || ext == ".exe"
+// API 请求（/api/ 路径或 Accept: application/json）返回 JSON，否则渲染页面
+func wantsJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || strings.Contains(r.Header.Get("Accept"), "application/json")
 }
 
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
+func respondUpgrade(w http.ResponseWriter, r *http.Request, profile *upgrader.Profile, result *upgrader.UpgradeResult, code int) {
+	if wantsJSON(r) {
+		writeJSON(w, code, result)
+		return
 	}
-	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
+	messageType := "error"
+	if result.Success {
+		messageType = "success"
 	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	return err
+	showResult(w, r, http.StatusOK, profile, result.Message, messageType, result.Logs)
 }
 
-func runCommand(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	return cmd.Run()
-}
-
-func showResult(w http.ResponseWriter, message, messageType, logs string) {
+// 显示页面，设置会话 Cookie 后再写入状态码 code
+func showResult(w http.ResponseWriter, r *http.Request, code int, profile *upgrader.Profile, message, messageType, logs string) {
 	tmpl := template.Must(template.New("upload").Parse(htmlTemplate))
 	data := PageData{
 		Config:         appConfig,
+		Profile:        profile,
+		Profiles:       engine.Profiles(),
 		Message:        message,
 		MessageType:    messageType,
 		Logs:           logs,
-		AcceptTypesStr: strings.Join(appConfig.AcceptTypes, ","),
+		AcceptTypesStr: strings.Join(profile.AcceptTypes, ","),
+		Deployed:       profile.Deployed(),
+		Pending:        profile.Pending(),
+		Flow:           profile.Flow(),
+		History:        profile.History(),
+		CSRFToken:      csrfTokenFor(ensureSession(w, r)),
+		Version:        VERSION,
 	}
-	tmpl.Execute(w, data)
-}
-
-// 工具函数：将字符串权限转换为 os.FileMode
-func getPermission(permStr string) os.FileMode {
-	if perm, err := strconv.ParseUint(permStr, 8, 32); err == nil {
-		return os.FileMode(perm)
+	if name := builtinProfile(); name != "" {
+		data.SelfUpdateBlocked = "升级本程序不可用：" + selfUpdateBuiltinMessage(name)
+	}
+	if autoUpdater != nil {
+		status := autoUpdater.snapshot()
+		data.Feed = &status
+		data.FeedProfile = status.profile(profile.Name)
 	}
-	return 0755 // 默认权限
+	w.WriteHeader(code)
+	tmpl.Execute(w, data)
 }
 
 // 加载配置文件
@@ -869,6 +1006,7 @@ func loadConfig(configPath string) (*Config, error) {
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %v", err)
 	}
+	config.ApplyDefaults()
 
 	return &config, nil
 }
@@ -897,8 +1035,21 @@ func overrideConfigFromEnv(config *Config) {
 	if val := os.Getenv("SERVICE_NAME"); val != "" {
 		config.ServiceName = val
 	}
+	if val := os.Getenv("STATE_DIR"); val != "" {
+		config.StateDir = val
+	}
+	if val := os.Getenv("SERVICE_MANAGER"); val != "" {
+		config.ServiceManager = val
+	}
 	if val := os.Getenv("PORT"); val != "" {
-		config.Port = val
+		config.Port = ""
+		config.Listen = strings.Split(val, ",")
+	}
+	if val := os.Getenv("GRPC_PORT"); val != "" {
+		config.GRPCPort = val
+	}
+	if val := os.Getenv("API_TOKEN"); val != "" {
+		config.APIToken = val
 	}
 	if val := os.Getenv("MAX_FILE_SIZE"); val != "" {
 		if size, err := strconv.ParseInt(val, 10, 64); err == nil {
@@ -920,13 +1071,20 @@ func main() {
 	// 命令行参数
 	var (
 		configPath  = flag.String("config", "./config.json", "配置文件路径")
-		port        = flag.String("port", "", "服务端口 (覆盖配置文件)")
+		port        = flag.String("port", "", "服务端口或监听地址，多个地址用逗号分隔 (覆盖配置文件)")
+		grpcPort    = flag.String("grpc-port", "", "gRPC 端口或监听地址 (覆盖配置文件)")
 		targetDir   = flag.String("target", "", "目标目录 (覆盖配置文件)")
 		serviceName = flag.String("service", "", "服务名称 (覆盖配置文件)")
 		genConfig   = flag.Bool("gen-config", false, "生成默认配置文件并退出")
+		showVersion = flag.Bool("version", false, "显示版本并退出")
 	)
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Println(VERSION)
+		return
+	}
+
 	// 生成配置文件
 	if *genConfig {
 		defaultConfig := getDefaultConfig()
@@ -949,7 +1107,11 @@ func main() {
 
 	// 从命令行参数覆盖配置
 	if *port != "" {
-		appConfig.Port = *port
+		appConfig.Port = ""
+		appConfig.Listen = strings.Split(*port, ",")
+	}
+	if *grpcPort != "" {
+		appConfig.GRPCPort = *grpcPort
 	}
 	if *targetDir != "" {
 		appConfig.TargetDir = *targetDir
@@ -958,16 +1120,99 @@ func main() {
 		appConfig.ServiceName = *serviceName
 	}
 
-	// 确保端口格式正确
-	if !strings.HasPrefix(appConfig.Port, ":") {
-		appConfig.Port = ":" + appConfig.Port
+	// 创建升级引擎：初始化配置档并加载部署记录
+	engine, err = upgrader.New(appConfig.Config)
+	if err != nil {
+		log.Fatalf("加载配置档失败: %v", err)
+	}
+	// 应用确认需要令牌：页面的 CSRF 令牌只属于浏览器会话，被管理的应用无法获得
+	for _, p := range engine.Profiles() {
+		if p.ConfirmTimeout > 0 && appConfig.APIToken == "" && p.ConfirmToken == "" {
+			log.Fatalf("配置档 %s 启用了应用确认 (confirm_timeout)，但未配置 api_token 或 confirm_token，应用无法确认升级，每次升级都会被自动恢复", p.Name)
+		}
+	}
+	if appConfig.Download.AuthHeader != "" && len(appConfig.Download.AuthHosts) == 0 {
+		log.Printf("download.auth_header 已配置，但 download.auth_hosts 为空，下载时不会发送该请求头")
+	}
+	access, err = newAccessControl(appConfig.Access)
+	if err != nil {
+		log.Fatalf("加载访问控制配置失败: %v", err)
+	}
+
+	// 打开监听：优先使用 systemd socket 激活传入的监听，名称为 grpc 的用于 gRPC
+	inherited, err := inheritedListeners()
+	if err != nil {
+		log.Fatalf("加载 socket 激活的监听失败: %v", err)
+	}
+	// 自更新启动的新进程：就绪后通过管道通知旧进程
+	readyPipe := handoverReadyPipe()
+	grpcListeners = inherited["grpc"]
+	delete(inherited, "grpc")
+	for _, list := range inherited {
+		httpListeners = append(httpListeners, list...)
+	}
+	if len(httpListeners) == 0 {
+		addrs := appConfig.Listen
+		if len(addrs) == 0 {
+			addrs = []string{appConfig.Port}
+		}
+		if httpListeners, err = listenAll(addrs); err != nil {
+			log.Fatalf("启动服务器失败: %v", err)
+		}
+	}
+	if len(grpcListeners) == 0 && appConfig.GRPCPort != "" {
+		if grpcListeners, err = listenAll(strings.Split(appConfig.GRPCPort, ",")); err != nil {
+			log.Fatalf("启动 gRPC 服务失败: %v", err)
+		}
+	}
+	var grpcSrv *grpc.Server
+	if len(grpcListeners) > 0 {
+		if grpcSrv, err = newGRPCServer(grpcListeners, access); err != nil {
+			log.Fatalf("启动 gRPC 服务失败: %v", err)
+		}
 	}
 
 	// 检查是否以 root 权限运行
-	if os.Geteuid() != 0 && appConfig.EnableService {
+	if os.Geteuid() != 0 && anyServiceEnabled() {
 		log.Println("警告：建议以 root 权限运行以确保能够操作系统服务")
 	}
 
+	// 恢复待确认的升级、启动内置守护的进程，并在退出时停止
+	engine.Start()
+
+	// 发布源自动升级（可选），先于 MQTT 启动，MQTT 升级命令使用其公钥校验签名
+	if appConfig.Feed != nil && appConfig.Feed.URL != "" {
+		autoUpdater, err = startFeed(appConfig.Feed)
+		if err != nil {
+			log.Fatalf("启动发布源自动升级失败: %v", err)
+		}
+	}
+
+	// 连接 MQTT broker（可选）
+	if appConfig.MQTT != nil && appConfig.MQTT.Broker != "" {
+		mqttClient, err = startMQTT(appConfig.MQTT)
+		if err != nil {
+			log.Fatalf("启动 MQTT 客户端失败: %v", err)
+		}
+	}
+
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-sigCh
+		log.Printf("收到信号 %v，正在退出", sig)
+		closeListeners(httpListeners)
+		closeListeners(grpcListeners)
+		if mqttClient != nil {
+			mqttClient.close()
+		}
+		if autoUpdater != nil {
+			autoUpdater.close()
+		}
+		engine.Close()
+		os.Exit(0)
+	}()
+
 	// 启动清理任务（可选）
 	if appConfig.EnableCleanup {
 		go func() {
@@ -982,22 +1227,72 @@ func main() {
 
 	// 设置路由
 	http.Handle("/", &UpgradeHandler{})
+	// 上传的 CSRF 令牌在流式读取表单时校验
 	http.HandleFunc("/upload", uploadHandler)
+	http.HandleFunc("/api/upload", uploadHandler)
+	http.HandleFunc("/uploads", csrfProtect(resumableHandler))
+	http.HandleFunc("/uploads/", csrfProtect(resumableHandler))
+	http.HandleFunc("/api/uploads", csrfProtect(resumableHandler))
+	http.HandleFunc("/api/uploads/", csrfProtect(resumableHandler))
 	http.HandleFunc("/banner", bannerHandler)
+	http.HandleFunc("/api/status", statusHandler)
+	http.HandleFunc("/api/history", historyHandler)
+	http.HandleFunc("/api/confirm", csrfProtect(confirmHandler))
+	http.HandleFunc("/api/backups", backupsHandler)
+	http.HandleFunc("/api/restore", csrfProtect(restoreHandler))
+	http.HandleFunc("/service", csrfProtect(serviceHandler))
+	http.HandleFunc("/api/service", csrfProtect(serviceHandler))
+	http.HandleFunc("/feed", csrfProtect(feedHandler))
+	http.HandleFunc("/api/feed", csrfProtect(feedHandler))
+	http.HandleFunc("/channel", csrfProtect(channelHandler))
+	http.HandleFunc("/api/channel", csrfProtect(channelHandler))
+	// 与上传相同，CSRF 令牌在流式读取表单时校验
+	http.HandleFunc("/self-update", selfUpdateHandler)
+	http.HandleFunc("/api/self-update", selfUpdateHandler)
 
 	// 启动服务器
-	log.Printf("程序升级系统启动成功")
+	log.Printf("程序升级系统启动成功 (版本 %s)", VERSION)
 	log.Printf("配置文件: %s", *configPath)
-	log.Printf("访问地址: http://localhost%s", appConfig.Port)
-	log.Printf("目标目录: %s", appConfig.TargetDir)
-	log.Printf("服务名称: %s", appConfig.ServiceName)
-	log.Printf("备份功能: %v", appConfig.EnableBackup)
-	log.Printf("服务管理: %v", appConfig.EnableService)
+	for _, l := range httpListeners {
+		log.Printf("访问地址: %s", listenURL(l))
+	}
+	for _, p := range engine.Profiles() {
+		log.Printf("配置档 [%s]: 目标目录=%s, 服务=%s (%s), 备份=%v, 服务管理=%v", p.Name, p.TargetDir, p.ServiceName, p.ManagerName(), p.BackupEnabled(), p.ServiceEnabled())
+		if record := p.Deployed(); record != nil {
+			log.Printf("配置档 [%s]: 当前版本 %s (部署于 %s)", p.Name, record.DisplayVersion(), record.DeployedAt.Format("2006-01-02 15:04:05"))
+		} else {
+			log.Printf("配置档 [%s]: 尚无部署记录", p.Name)
+		}
+	}
 	log.Printf("文件清理: %v", appConfig.EnableCleanup)
+	if autoUpdater != nil {
+		log.Printf("发布源自动升级: %s (间隔 %v)", appConfig.Feed.URL, autoUpdater.interval)
+	}
 
-	if err := http.ListenAndServe(appConfig.Port, nil); err != nil {
-		log.Fatal("启动服务器失败：", err)
+	// 启动 gRPC 服务（可选）
+	if grpcSrv != nil {
+		for _, l := range grpcListeners {
+			log.Printf("gRPC 地址: %s", l.Addr())
+		}
+		go func() {
+			if err := serveGRPC(grpcSrv, grpcListeners); err != nil && !errors.Is(err, net.ErrClosed) {
+				log.Fatal("gRPC 服务异常退出：", err)
+			}
+		}()
 	}
+
+	httpServer = &http.Server{Handler: access.wrap(http.DefaultServeMux)}
+	errCh := make(chan error, len(httpListeners))
+	for _, l := range httpListeners {
+		go func() { errCh <- httpServer.Serve(l) }()
+	}
+	// 通知 systemd 或启动本进程的旧进程已就绪
+	notifyReady(readyPipe)
+	if err := <-errCh; !errors.Is(err, net.ErrClosed) && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal("服务器异常退出：", err)
+	}
+	// 监听由信号处理或自更新关闭，等待其完成退出
+	select {}
 }
 
 func cleanupOldFiles(dir string, maxAge time.Duration) {
@@ -1016,4 +1311,4 @@ func cleanupOldFiles(dir string, maxAge time.Duration) {
 		return nil
 	})
 	log.Printf("清理完成，共删除 %d 个文件", count)
-}
\ No newline at end of file
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"linker-upgrader/upgrader"
+)
+
+// 测试使用的引擎：临时目录，不管理服务
+func setupTestEngine(t *testing.T) {
+	t.Helper()
+	setupUploadDir(t)
+	dir := t.TempDir()
+	appConfig.Config = upgrader.DefaultConfig()
+	appConfig.TargetDir = filepath.Join(dir, "target")
+	appConfig.BackupDir = filepath.Join(dir, "backup")
+	appConfig.StateDir = filepath.Join(dir, "state")
+	appConfig.EnableService = false
+	appConfig.Download = DownloadConfig{Retries: 1}
+
+	old := engine
+	var err error
+	if engine, err = upgrader.New(appConfig.Config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { engine = old })
+}
+
+// 只包含一个文件的 tar.gz 升级包
+func testPackage(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "app.txt", Mode: 0644, Size: int64(len(content))})
+	tw.Write([]byte(content))
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// 本地文件服务器：/files/ 下为升级包
+type testPackageServer struct {
+	*httptest.Server
+	mux      *http.ServeMux
+	packages map[string][]byte
+}
+
+func newTestPackageServer(t *testing.T) *testPackageServer {
+	s := &testPackageServer{mux: http.NewServeMux(), packages: make(map[string][]byte)}
+	s.mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := s.packages[strings.TrimPrefix(r.URL.Path, "/files/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+	s.Server = httptest.NewServer(s.mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// 添加一个版本的升级包，返回下载地址和 SHA256
+func (s *testPackageServer) add(t *testing.T, version string) (string, string) {
+	name := "app-" + version + ".tar.gz"
+	data := testPackage(t, version)
+	s.packages[name] = data
+	return s.URL + "/files/" + name, sha256Hex(data)
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"linker-upgrader/upgrader"
+)
+
+// MQTT 客户端模式配置
+type MQTTConfig struct {
+	Broker      string         `json:"broker"`    // tcp://localhost:1883 / ssl://host:8883 / ws://host:9001
+	ClientID    string         `json:"client_id"` // 为空时使用 linker-upgrader-<device_id>
+	Username    string         `json:"username"`
+	Password    string         `json:"password"`
+	DeviceID    string         `json:"device_id"`    // 为空时使用主机名
+	TopicPrefix string         `json:"topic_prefix"` // 为空时使用 linker-upgrader
+	QoS         byte           `json:"qos"`          // 0 / 1 / 2
+	KeepAlive   int            `json:"keep_alive"`   // 秒，为空时使用 30
+	AllowForce  bool           `json:"allow_force"`  // 是否接受 upgrade 命令的 force
+	TLS         *MQTTTLSConfig `json:"tls,omitempty"`
+}
+
+// MQTT TLS 配置，证书均为 PEM 文件
+type MQTTTLSConfig struct {
+	CAFile             string `json:"ca_file"`
+	CertFile           string `json:"cert_file"` // 客户端证书，与 key_file 同时配置
+	KeyFile            string `json:"key_file"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// MQTT 命令，发布到 <topic_prefix>/<device_id>/cmd
+type mqttCommand struct {
+	ID        string `json:"id"`        // 原样返回，用于对应响应
+	Action    string `json:"action"`    // upgrade / restart / rollback
+	Profile   string `json:"profile"`   // 为空时使用第一个配置档
+	URL       string `json:"url"`       // upgrade: 升级包地址
+	Filename  string `json:"filename"`  // upgrade: 为空时取 URL 路径的文件名
+	SHA256    string `json:"sha256"`    // upgrade: 下载后校验，配置 feed.public_key 时必须提供
+	Signature string `json:"signature"` // upgrade: 与发布源相同的签名，配置 feed.public_key 时必须提供
+	Force     bool   `json:"force"`     // upgrade: 忽略版本策略，需配置 allow_force
+	Backup    string `json:"backup"`    // rollback: 为空时使用最新的备份
+	Token     string `json:"token"`     // 配置 api_token 时必须提供
+}
+
+// 命令执行结果，发布到 <topic_prefix>/<device_id>/response
+type mqttResponse struct {
+	ID      string    `json:"id,omitempty"`
+	Action  string    `json:"action"`
+	Profile string    `json:"profile"`
+	Success bool      `json:"success"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// 退出时等待进行中的命令（例如升级及其观察期）的最长时间
+const mqttCloseTimeout = 30 * time.Second
+
+type mqttAgent struct {
+	config   *MQTTConfig
+	base     string // <topic_prefix>/<device_id>
+	client   mqtt.Client
+	commands chan mqttCommand
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{} // run 返回时关闭
+}
+
+// 状态主题（均为保留消息）：
+//
+//	<base>/availability        online / offline（遗嘱消息）
+//	<base>/<profile>/status    配置档状态 JSON
+//	<base>/<profile>/version   当前版本号
+//	<base>/<profile>/progress  最近一次升级事件 JSON
+func startMQTT(config *MQTTConfig) (*mqttAgent, error) {
+	deviceID := config.DeviceID
+	if deviceID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("获取主机名失败: %v", err)
+		}
+		deviceID = hostname
+	}
+	prefix := config.TopicPrefix
+	if prefix == "" {
+		prefix = "linker-upgrader"
+	}
+	if config.QoS > 2 {
+		return nil, fmt.Errorf("qos 必须为 0、1 或 2")
+	}
+
+	a := &mqttAgent{
+		config:   config,
+		base:     strings.TrimSuffix(prefix, "/") + "/" + deviceID,
+		commands: make(chan mqttCommand, 16),
+		done:     make(chan struct{}),
+	}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+
+	clientID := config.ClientID
+	if clientID == "" {
+		clientID = "linker-upgrader-" + deviceID
+	}
+	keepAlive := config.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = 30
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID(clientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetKeepAlive(time.Duration(keepAlive)*time.Second).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10*time.Second).
+		SetWill(a.topic("availability"), "offline", config.QoS, true).
+		SetOnConnectHandler(a.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("MQTT 连接断开: %v", err)
+		})
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.load()
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	a.client = mqtt.NewClient(opts)
+	// 开启连接重试后 Connect 在首次连接成功前不会返回错误，连接成功时由 onConnect 订阅命令主题
+	a.client.Connect()
+	go a.run()
+	return a, nil
+}
+
+func (c *MQTTTLSConfig) load() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		data, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 MQTT CA 证书失败: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("解析 MQTT CA 证书失败: %s", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载 MQTT 客户端证书失败: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func (a *mqttAgent) topic(parts ...string) string {
+	return a.base + "/" + strings.Join(parts, "/")
+}
+
+// 连接（或重连）成功：订阅命令主题并发布当前状态
+func (a *mqttAgent) onConnect(client mqtt.Client) {
+	log.Printf("MQTT 已连接: %s, 命令主题: %s", a.config.Broker, a.topic("cmd"))
+	token := client.Subscribe(a.topic("cmd"), a.config.QoS, a.onCommand)
+	if token.Wait() && token.Error() != nil {
+		log.Printf("MQTT 订阅命令主题失败: %v", token.Error())
+	}
+	a.publish(a.topic("availability"), true, "online")
+	for _, p := range engine.Profiles() {
+		a.publishStatus(p.Name)
+	}
+}
+
+// 命令在单独的 goroutine 中依次执行，避免阻塞 MQTT 客户端
+func (a *mqttAgent) onCommand(_ mqtt.Client, msg mqtt.Message) {
+	var cmd mqttCommand
+	if err := json.Unmarshal(msg.Payload(), &cmd); err != nil {
+		log.Printf("MQTT 命令格式错误: %v", err)
+		a.respond(mqttResponse{Action: "unknown", Message: "命令格式错误: " + err.Error()})
+		return
+	}
+	select {
+	case a.commands <- cmd:
+	default:
+		a.respond(mqttResponse{ID: cmd.ID, Action: cmd.Action, Profile: cmd.Profile, Message: "命令队列已满"})
+	}
+}
+
+// 依次执行命令，close 后返回，尚未开始的命令被丢弃
+func (a *mqttAgent) run() {
+	defer close(a.done)
+	for {
+		select {
+		case cmd := <-a.commands:
+			if a.ctx.Err() != nil {
+				return
+			}
+			a.handle(cmd)
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *mqttAgent) handle(cmd mqttCommand) {
+	resp := mqttResponse{ID: cmd.ID, Action: cmd.Action, Profile: cmd.Profile}
+	if err := a.authorize(cmd); err != nil {
+		resp.Message = err.Error()
+		a.respond(resp)
+		return
+	}
+	profile := engine.Profile(cmd.Profile)
+	if profile == nil {
+		resp.Message = "配置档不存在: " + cmd.Profile
+		a.respond(resp)
+		return
+	}
+	resp.Profile = profile.Name
+	remote := "mqtt:" + a.base
+	log.Printf("[%s] MQTT 命令: %s", profile.Name, cmd.Action)
+
+	ctx := context.Background()
+	onEvent := func(e upgrader.Event) {
+		a.publishJSON(a.topic(e.Profile, "progress"), true, e)
+	}
+
+	var err error
+	switch cmd.Action {
+	case "upgrade":
+		var result *upgrader.UpgradeResult
+		result, err = a.upgradeFromURL(profile.Name, cmd, remote, onEvent)
+		if result != nil {
+			resp.Message = result.Message
+		}
+	case "restart":
+		if _, err = engine.ControlService(ctx, profile.Name, "restart", remote); err == nil {
+			resp.Message = fmt.Sprintf("服务 %s 已重启", profile.ServiceName)
+		}
+	case "rollback":
+		var result *upgrader.UpgradeResult
+		result, err = engine.Restore(ctx, upgrader.RestoreRequest{Profile: profile.Name, Backup: cmd.Backup, Remote: remote}, onEvent)
+		resp.Message = result.Message
+	default:
+		err = fmt.Errorf("不支持的命令: %s", cmd.Action)
+	}
+
+	resp.Success = err == nil
+	if err != nil && resp.Message == "" {
+		resp.Message = err.Error()
+	}
+	a.respond(resp)
+	a.publishStatus(profile.Name)
+}
+
+// MQTT 命令没有客户端地址，所有命令共用一个频率计数
+var mqttRateKey netip.Addr
+
+// 与 HTTP、gRPC 接口相同的 API 令牌，令牌错误计入认证失败次数
+func (a *mqttAgent) authorize(cmd mqttCommand) error {
+	if appConfig.APIToken == "" {
+		return nil
+	}
+	limited := access != nil && access.login != nil
+	if limited {
+		if wait := access.login.wait(mqttRateKey); wait > 0 {
+			log.Printf("拒绝认证: MQTT %s (认证失败次数过多)", cmd.Action)
+			return fmt.Errorf("认证失败次数过多，请在 %d 秒后再试", int(math.Ceil(wait.Seconds())))
+		}
+	}
+	if !validAPIToken("Bearer " + cmd.Token) {
+		log.Printf("MQTT 命令令牌认证失败: %s", cmd.Action)
+		if limited {
+			access.login.take(mqttRateKey)
+		}
+		return fmt.Errorf("未认证")
+	}
+	return nil
+}
+
+// 下载升级包后执行升级。退出时中断下载，但不中断进行中的升级
+func (a *mqttAgent) upgradeFromURL(profile string, cmd mqttCommand, remote string, onEvent upgrader.EventFunc) (*upgrader.UpgradeResult, error) {
+	if cmd.URL == "" {
+		return nil, fmt.Errorf("缺少升级包地址 url")
+	}
+	if cmd.Force && !a.config.AllowForce {
+		return nil, fmt.Errorf("未允许通过 MQTT 强制升级 (mqtt.allow_force)")
+	}
+	// 与发布源相同，配置公钥后只接受签名的升级包
+	if autoUpdater != nil && autoUpdater.key != nil {
+		if err := autoUpdater.verify(&feedRelease{URL: cmd.URL, SHA256: cmd.SHA256, Signature: cmd.Signature}); err != nil {
+			log.Printf("拒绝 MQTT 升级 %s: %v", cmd.URL, err)
+			return nil, err
+		}
+	}
+	// 每个升级包计入一次上传频率
+	if access != nil && access.upload != nil {
+		if wait := access.upload.take(mqttRateKey); wait > 0 {
+			log.Printf("拒绝上传: MQTT %s (上传过于频繁)", cmd.URL)
+			return nil, fmt.Errorf("上传过于频繁，请在 %d 秒后再试", int(math.Ceil(wait.Seconds())))
+		}
+	}
+	upload, err := downloadPackage(a.ctx, downloadRequest{
+		URL:      cmd.URL,
+		Filename: cmd.Filename,
+		SHA256:   cmd.SHA256,
+		Uploader: remote,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return engine.Upgrade(context.WithoutCancel(a.ctx), upload.upgradeRequest(profile, cmd.Force, remote), onEvent)
+}
+
+func (a *mqttAgent) respond(resp mqttResponse) {
+	resp.Time = time.Now()
+	a.publishJSON(a.topic("response"), false, resp)
+}
+
+// 发布配置档状态与版本
+func (a *mqttAgent) publishStatus(profile string) {
+	statuses, err := engine.Status(context.Background(), profile)
+	if err != nil {
+		log.Printf("[%s] 获取状态失败: %v", profile, err)
+		return
+	}
+	status := statuses[0]
+	a.publishJSON(a.topic(profile, "status"), true, status)
+	version := ""
+	if status.Deployed != nil {
+		version = status.Deployed.Version
+	}
+	a.publish(a.topic(profile, "version"), true, version)
+}
+
+func (a *mqttAgent) publishJSON(topic string, retained bool, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("MQTT 消息序列化失败: %v", err)
+		return
+	}
+	a.publish(topic, retained, data)
+}
+
+// 未连接时丢弃消息，重连后 onConnect 会重新发布状态
+func (a *mqttAgent) publish(topic string, retained bool, payload any) {
+	if !a.client.IsConnectionOpen() {
+		return
+	}
+	token := a.client.Publish(topic, a.config.QoS, retained, payload)
+	go func() {
+		if token.WaitTimeout(10*time.Second) && token.Error() != nil {
+			log.Printf("MQTT 发布 %s 失败: %v", topic, token.Error())
+		}
+	}()
+}
+
+// 停止接收和执行命令，等待进行中的命令完成（最多 mqttCloseTimeout）后发布离线状态并断开连接
+func (a *mqttAgent) close() {
+	a.cancel()
+	if a.client.IsConnectionOpen() {
+		a.client.Unsubscribe(a.topic("cmd")).WaitTimeout(2 * time.Second)
+	}
+	select {
+	case <-a.done:
+	default:
+		log.Printf("等待进行中的 MQTT 命令结束，最多 %v", mqttCloseTimeout)
+		select {
+		case <-a.done:
+		case <-time.After(mqttCloseTimeout):
+			log.Printf("MQTT 命令仍未结束，不再等待")
+		}
+	}
+	if a.client.IsConnectionOpen() {
+		a.client.Publish(a.topic("availability"), a.config.QoS, true, "offline").WaitTimeout(2 * time.Second)
+	}
+	a.client.Disconnect(250)
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// 本地 MQTT 服务器，返回地址和收到的消息（主题 -> 消息）
+func startTestBroker(t *testing.T, topics ...string) (*mochi.Server, string, <-chan [2]string) {
+	t.Helper()
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := server.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+
+	messages := make(chan [2]string, 64)
+	for i, topic := range topics {
+		err := server.Subscribe(topic, i+1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+			messages <- [2]string{pk.TopicName, string(pk.Payload)}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return server, "tcp://" + tcp.Address(), messages
+}
+
+// 等待指定主题的消息
+func waitMessage(t *testing.T, messages <-chan [2]string, topic string) string {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case m := <-messages:
+			if m[0] == topic {
+				return m[1]
+			}
+		case <-timeout:
+			t.Fatalf("等待 %s 超时", topic)
+		}
+	}
+}
+
+func waitResponse(t *testing.T, messages <-chan [2]string) mqttResponse {
+	t.Helper()
+	var resp mqttResponse
+	if err := json.Unmarshal([]byte(waitMessage(t, messages, "test/dev/response")), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestMQTTAgent(t *testing.T) {
+	setupTestEngine(t)
+	packages := newTestPackageServer(t)
+	url, _ := packages.add(t, "1.1.0")
+
+	broker, addr, messages := startTestBroker(t, "test/dev/availability", "test/dev/response")
+	agent, err := startMQTT(&MQTTConfig{Broker: addr, DeviceID: "dev", TopicPrefix: "test", QoS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := false
+	defer func() {
+		if !closed {
+			agent.close()
+		}
+	}()
+	// 连接后先订阅命令主题，再发布 online
+	if got := waitMessage(t, messages, "test/dev/availability"); got != "online" {
+		t.Fatalf("availability = %s", got)
+	}
+
+	command := func(cmd mqttCommand) mqttResponse {
+		t.Helper()
+		data, _ := json.Marshal(cmd)
+		if err := broker.Publish("test/dev/cmd", data, false, 1); err != nil {
+			t.Fatal(err)
+		}
+		return waitResponse(t, messages)
+	}
+
+	resp := command(mqttCommand{ID: "1", Action: "upgrade", URL: url})
+	if !resp.Success || resp.ID != "1" || resp.Profile != "default" {
+		t.Fatalf("upgrade: %+v", resp)
+	}
+	if deployed := engine.Profile("").Deployed(); deployed == nil || deployed.Version != "1.1.0" {
+		t.Errorf("已部署 %+v", deployed)
+	}
+
+	tests := []struct {
+		name string
+		cmd  mqttCommand
+	}{
+		{"缺少 url", mqttCommand{ID: "2", Action: "upgrade"}},
+		{"配置档不存在", mqttCommand{ID: "3", Action: "upgrade", Profile: "missing"}},
+		{"不支持的命令", mqttCommand{ID: "4", Action: "reboot"}},
+		{"未允许 force", mqttCommand{ID: "5", Action: "upgrade", URL: url, Force: true}},
+	}
+	for _, tt := range tests {
+		if resp := command(tt.cmd); resp.Success || resp.ID != tt.cmd.ID || resp.Message == "" {
+			t.Errorf("%s: %+v", tt.name, resp)
+		}
+	}
+
+	// close 停止执行命令的 goroutine，并发布离线状态
+	agent.close()
+	closed = true
+	select {
+	case <-agent.done:
+	default:
+		t.Error("close 返回后命令 goroutine 仍在运行")
+	}
+	if got := waitMessage(t, messages, "test/dev/availability"); got != "offline" {
+		t.Errorf("availability = %s", got)
+	}
+}
+
+func TestMQTTAgentCloseDisconnected(t *testing.T) {
+	setupTestEngine(t)
+	// 服务器不存在时 close 也应立即返回
+	agent, err := startMQTT(&MQTTConfig{Broker: "tcp://127.0.0.1:1", DeviceID: "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		agent.close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("close 未返回")
+	}
+}
+
+func TestMQTTAgentToken(t *testing.T) {
+	setupTestEngine(t)
+	appConfig.APIToken = "api"
+
+	broker, addr, messages := startTestBroker(t, "test/dev/availability", "test/dev/response")
+	agent, err := startMQTT(&MQTTConfig{Broker: addr, DeviceID: "dev", TopicPrefix: "test", QoS: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.close()
+	waitMessage(t, messages, "test/dev/availability")
+
+	// 令牌正确时才执行命令（此处为不支持的命令）
+	for _, token := range []string{"", "wrong", "api"} {
+		data, _ := json.Marshal(mqttCommand{ID: token, Action: "reboot", Token: token})
+		if err := broker.Publish("test/dev/cmd", data, false, 1); err != nil {
+			t.Fatal(err)
+		}
+		resp := waitResponse(t, messages)
+		if authenticated := resp.Message != "未认证"; resp.Success || authenticated != (token == "api") {
+			t.Errorf("token %q: %+v", token, resp)
+		}
+	}
+}
+
+// MQTT 命令共用一个认证失败计数
+func TestMQTTAgentLoginRate(t *testing.T) {
+	setupTestEngine(t)
+	appConfig.APIToken = "api"
+	old := access
+	var err error
+	if access, err = newAccessControl(AccessConfig{LoginRate: RateLimit{Requests: 1, Window: 60}}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { access = old }()
+
+	agent := &mqttAgent{config: &MQTTConfig{}}
+	if err := agent.authorize(mqttCommand{Action: "status", Token: "api"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := agent.authorize(mqttCommand{Action: "status", Token: "wrong"}); err == nil || err.Error() != "未认证" {
+		t.Errorf("令牌错误: %v", err)
+	}
+	// 超过 login_rate 后令牌正确也拒绝
+	if err := agent.authorize(mqttCommand{Action: "status", Token: "api"}); err == nil || !strings.Contains(err.Error(), "认证失败次数过多") {
+		t.Errorf("超过 login_rate: %v", err)
+	}
+}
+
+// 每条 upgrade 命令计入一次 upload_rate
+func TestMQTTAgentUploadRate(t *testing.T) {
+	setupTestEngine(t)
+	old := access
+	var err error
+	if access, err = newAccessControl(AccessConfig{UploadRate: RateLimit{Requests: 1, Window: 60}}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { access = old }()
+
+	agent := &mqttAgent{config: &MQTTConfig{}, ctx: context.Background()}
+	cmd := mqttCommand{Action: "upgrade", URL: "http://127.0.0.1:1/app-1.0.0.tar.gz"}
+	if _, err := agent.upgradeFromURL("", cmd, "mqtt", nil); err == nil || strings.Contains(err.Error(), "上传过于频繁") {
+		t.Errorf("第一次下载应失败于连接: %v", err)
+	}
+	if _, err := agent.upgradeFromURL("", cmd, "mqtt", nil); err == nil || !strings.Contains(err.Error(), "上传过于频繁") {
+		t.Errorf("超过 upload_rate: %v", err)
+	}
+}
+
+// 配置了发布源公钥时，upgrade 命令必须带有签名
+func TestMQTTAgentSignature(t *testing.T) {
+	setupTestEngine(t)
+	packages := newTestFeedServer(t)
+	packages.publish(t, "1.1.0", "")
+	release := packages.feed.Releases[0]
+
+	public, private, _ := ed25519.GenerateKey(nil)
+	digest, _ := hex.DecodeString(release.SHA256)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(private, digest))
+	old := autoUpdater
+	autoUpdater = &feedAgent{key: public}
+	defer func() { autoUpdater = old }()
+
+	agent := &mqttAgent{config: &MQTTConfig{}, ctx: context.Background()}
+	tests := []struct {
+		name string
+		cmd  mqttCommand
+		ok   bool
+	}{
+		{"缺少 sha256", mqttCommand{URL: release.URL}, false},
+		{"缺少签名", mqttCommand{URL: release.URL, SHA256: release.SHA256}, false},
+		{"签名有效", mqttCommand{URL: release.URL, SHA256: release.SHA256, Signature: signature}, true},
+	}
+	for _, tt := range tests {
+		tt.cmd.Action = "upgrade"
+		if _, err := agent.upgradeFromURL("", tt.cmd, "mqtt", nil); (err == nil) != tt.ok {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+	if deployed := engine.Profile("").Deployed(); deployed == nil || deployed.Version != "1.1.0" {
+		t.Errorf("已部署 %+v", deployed)
+	}
+}
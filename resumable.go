@@ -0,0 +1,277 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"linker-upgrader/upgrader"
+)
+
+// 断点续传上传，兼容 tus 1.0 核心协议及 creation、termination 扩展：
+//
+//	POST   /api/uploads        创建上传，请求头 Upload-Length 和 Upload-Metadata（filename）
+//	HEAD   /api/uploads/<id>   查询已接收的字节数 Upload-Offset
+//	PATCH  /api/uploads/<id>   从 Upload-Offset 处追加数据
+//	DELETE /api/uploads/<id>   取消上传
+//
+// 页面使用 /uploads，与 /upload 一样适用页面的访问控制，其余相同。
+// 接收完成后得到与普通上传相同的 ID，通过 /upload 或 /api/upload 的 upload_id 字段执行升级
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination"
+	tusOffsetType = "application/offset+octet-stream"
+)
+
+// 正在写入的上传，同一上传不允许并发 PATCH
+var (
+	resumableMu     sync.Mutex
+	resumableActive = make(map[string]bool)
+)
+
+// 未完成的上传数据
+func uploadPartPath(id string) string {
+	return filepath.Join(appConfig.UploadDir, id+".part")
+}
+
+func resumableHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if v := r.Header.Get("Tus-Resumable"); v != "" && v != tusVersion && r.Method != http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "不支持的 tus 版本", http.StatusPreconditionFailed)
+		return
+	}
+
+	base := "/uploads"
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		base = "/api/uploads"
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, base), "/")
+	switch {
+	case r.Method == http.MethodOptions:
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		if appConfig.MaxFileSize > 0 {
+			w.Header().Set("Tus-Max-Size", strconv.FormatInt(appConfig.MaxFileSize<<20, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case id == "" && r.Method == http.MethodPost:
+		createResumable(w, r, base)
+	case id == "":
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	case !validUploadID(id):
+		http.Error(w, "上传不存在", http.StatusNotFound)
+	case r.Method == http.MethodHead:
+		headResumable(w, id)
+	case r.Method == http.MethodPatch:
+		patchResumable(w, r, id)
+	case r.Method == http.MethodDelete:
+		deleteResumable(w, r, id)
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 解析 Upload-Metadata：逗号分隔的 "键 base64值"
+func parseUploadMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata
+}
+
+func createResumable(w http.ResponseWriter, r *http.Request, base string) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "缺少或无效的 Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if maxSize := appConfig.MaxFileSize << 20; maxSize > 0 && length > maxSize {
+		http.Error(w, fmt.Sprintf("文件大小超过限制 (%dMB)", appConfig.MaxFileSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	filename := sanitizeFilename(parseUploadMetadata(r.Header.Get("Upload-Metadata"))["filename"])
+	if filename == "" {
+		http.Error(w, "Upload-Metadata 缺少有效的 filename", http.StatusBadRequest)
+		return
+	}
+
+	if err := os.MkdirAll(appConfig.UploadDir, upgrader.ParsePermission(appConfig.DirPermission)); err != nil {
+		http.Error(w, "创建上传目录失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id, err := newUploadID()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	meta := &uploadMeta{ID: id, Filename: filename, Length: length, Uploader: r.RemoteAddr}
+	f, err := os.OpenFile(uploadPartPath(id), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err == nil {
+		f.Close()
+		err = saveUploadMeta(meta)
+	}
+	if err == nil && length == 0 {
+		err = completeResumable(meta)
+	}
+	if err != nil {
+		removeUpload(id)
+		http.Error(w, "创建上传失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("创建断点续传上传: %s (ID: %s), 大小: %d bytes (来自 %s)", filename, id, length, r.RemoteAddr)
+	w.Header().Set("Location", base+"/"+id)
+	w.Header().Set("Upload-Offset", "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// 读取上传的元数据和已接收的字节数
+func resumableState(id string) (*uploadMeta, int64, error) {
+	meta, err := readUploadMeta(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if info, err := os.Stat(uploadPartPath(id)); err == nil {
+		return meta, info.Size(), nil
+	}
+	if _, err := os.Stat(meta.path()); err == nil {
+		return meta, meta.Length, nil
+	}
+	return nil, 0, fmt.Errorf("上传不存在: %s", id)
+}
+
+func headResumable(w http.ResponseWriter, id string) {
+	w.Header().Set("Cache-Control", "no-store")
+	meta, offset, err := resumableState(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(meta.Length, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+func patchResumable(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != tusOffsetType {
+		http.Error(w, "Content-Type 必须为 "+tusOffsetType, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "缺少或无效的 Upload-Offset", http.StatusBadRequest)
+		return
+	}
+
+	resumableMu.Lock()
+	if resumableActive[id] {
+		resumableMu.Unlock()
+		http.Error(w, "该上传正在写入", http.StatusConflict)
+		return
+	}
+	resumableActive[id] = true
+	resumableMu.Unlock()
+	defer func() {
+		resumableMu.Lock()
+		delete(resumableActive, id)
+		resumableMu.Unlock()
+	}()
+
+	meta, current, err := resumableState(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if offset != current {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(current, 10))
+		http.Error(w, fmt.Sprintf("Upload-Offset 不匹配，当前为 %d", current), http.StatusConflict)
+		return
+	}
+	if current == meta.Length {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(current, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	f, err := os.OpenFile(uploadPartPath(id), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		http.Error(w, "打开上传文件失败: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// 连接中断时保留已接收的数据，客户端通过 HEAD 查询后继续
+	n, copyErr := io.Copy(f, http.MaxBytesReader(w, r.Body, meta.Length-current))
+	if err := f.Close(); copyErr == nil {
+		copyErr = err
+	}
+	current += n
+
+	if current == meta.Length {
+		if err := completeResumable(meta); err != nil {
+			http.Error(w, "保存文件失败: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("断点续传上传完成: %s (ID: %s), SHA256: %s", meta.Filename, id, meta.SHA256)
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(current, 10))
+
+	var maxErr *http.MaxBytesError
+	switch {
+	case errors.As(copyErr, &maxErr):
+		http.Error(w, "数据超出 Upload-Length", http.StatusRequestEntityTooLarge)
+	case copyErr != nil:
+		http.Error(w, "接收数据失败: "+copyErr.Error(), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// 接收完成：计算 SHA256 并转为普通上传文件
+func completeResumable(meta *uploadMeta) error {
+	f, err := os.Open(uploadPartPath(meta.ID))
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	meta.Size, err = io.Copy(hash, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	meta.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	meta.Uploaded = time.Now()
+	if err := os.Rename(uploadPartPath(meta.ID), meta.path()); err != nil {
+		return err
+	}
+	return saveUploadMeta(meta)
+}
+
+func deleteResumable(w http.ResponseWriter, r *http.Request, id string) {
+	if _, _, err := resumableState(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	removeUpload(id)
+	log.Printf("取消上传: %s (来自 %s)", id, r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func tusRequest(t *testing.T, method, path string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", tusVersion)
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	resumableHandler(w, r)
+	return w
+}
+
+func createTestUpload(t *testing.T, base string, length int) string {
+	t.Helper()
+	w := tusRequest(t, http.MethodPost, base, map[string]string{
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte("app.tar.gz")),
+	}, "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建上传: %d %s", w.Code, w.Body)
+	}
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, base+"/") {
+		t.Fatalf("Location = %s，应以 %s/ 开头", location, base)
+	}
+	return location
+}
+
+func patchChunk(t *testing.T, location string, offset int, data string) *httptest.ResponseRecorder {
+	t.Helper()
+	return tusRequest(t, http.MethodPatch, location, map[string]string{
+		"Content-Type":  tusOffsetType,
+		"Upload-Offset": strconv.Itoa(offset),
+	}, data)
+}
+
+func TestResumableUpload(t *testing.T) {
+	setupUploadDir(t)
+	for _, base := range []string{"/uploads", "/api/uploads"} {
+		t.Run(base, func(t *testing.T) {
+			location := createTestUpload(t, base, 10)
+
+			if w := patchChunk(t, location, 0, "hello"); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "5" {
+				t.Fatalf("第一块: %d, Upload-Offset %s", w.Code, w.Header().Get("Upload-Offset"))
+			}
+			// 偏移不一致时返回 409 和服务器当前的位置
+			for _, offset := range []int{0, 3, 7} {
+				w := patchChunk(t, location, offset, "world")
+				if w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != "5" {
+					t.Errorf("Upload-Offset %d: %d, Upload-Offset %s", offset, w.Code, w.Header().Get("Upload-Offset"))
+				}
+			}
+			w := tusRequest(t, http.MethodHead, location, nil, "")
+			if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "5" || w.Header().Get("Upload-Length") != "10" {
+				t.Fatalf("HEAD: %d, Upload-Offset %s", w.Code, w.Header().Get("Upload-Offset"))
+			}
+
+			// 超出 Upload-Length 的数据被拒绝，已接收的部分保留
+			if w := patchChunk(t, location, 5, "world!"); w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("超出长度: %d", w.Code)
+			}
+			offset, _ := strconv.Atoi(tusRequest(t, http.MethodHead, location, nil, "").Header().Get("Upload-Offset"))
+			if w := patchChunk(t, location, offset, "helloworld"[offset:]); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "10" {
+				t.Fatalf("最后一块: %d, Upload-Offset %s", w.Code, w.Header().Get("Upload-Offset"))
+			}
+
+			id := location[strings.LastIndex(location, "/")+1:]
+			meta, err := loadUpload(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := os.ReadFile(meta.path()); string(data) != "helloworld" || meta.Size != 10 {
+				t.Errorf("上传内容 %q, 大小 %d", data, meta.Size)
+			}
+			// 完成后重复发送最后的位置不会改变文件
+			if w := patchChunk(t, location, 10, ""); w.Code != http.StatusNoContent {
+				t.Errorf("完成后 PATCH: %d", w.Code)
+			}
+
+			if w := tusRequest(t, http.MethodDelete, location, nil, ""); w.Code != http.StatusNoContent {
+				t.Errorf("DELETE: %d", w.Code)
+			}
+			if w := tusRequest(t, http.MethodHead, location, nil, ""); w.Code != http.StatusNotFound {
+				t.Errorf("删除后 HEAD: %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestResumableConcurrentPatch(t *testing.T) {
+	setupUploadDir(t)
+	location := createTestUpload(t, "/api/uploads", 10)
+	id := location[strings.LastIndex(location, "/")+1:]
+
+	// 同一上传正在写入时，其他 PATCH 返回 409
+	resumableMu.Lock()
+	resumableActive[id] = true
+	resumableMu.Unlock()
+	w := patchChunk(t, location, 0, "hello")
+	resumableMu.Lock()
+	delete(resumableActive, id)
+	resumableMu.Unlock()
+	if w.Code != http.StatusConflict {
+		t.Errorf("并发 PATCH: %d", w.Code)
+	}
+	if w := patchChunk(t, location, 0, "hello"); w.Code != http.StatusNoContent {
+		t.Errorf("写入结束后 PATCH: %d", w.Code)
+	}
+}
+
+func TestResumableRequests(t *testing.T) {
+	setupUploadDir(t)
+	appConfig.MaxFileSize = 1
+	location := createTestUpload(t, "/uploads", 10)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"版本不支持", http.MethodHead, location, map[string]string{"Tus-Resumable": "0.2.0"}, http.StatusPreconditionFailed},
+		{"缺少 Upload-Length", http.MethodPost, "/uploads", map[string]string{"Upload-Metadata": "filename YS56aXA="}, http.StatusBadRequest},
+		{"超过大小限制", http.MethodPost, "/uploads", map[string]string{"Upload-Length": strconv.Itoa(2 << 20), "Upload-Metadata": "filename YS56aXA="}, http.StatusRequestEntityTooLarge},
+		{"缺少文件名", http.MethodPost, "/uploads", map[string]string{"Upload-Length": "1"}, http.StatusBadRequest},
+		{"文件名无效", http.MethodPost, "/uploads", map[string]string{"Upload-Length": "1", "Upload-Metadata": "filename Li4v"}, http.StatusBadRequest},
+		{"Content-Type 错误", http.MethodPatch, location, map[string]string{"Upload-Offset": "0"}, http.StatusUnsupportedMediaType},
+		{"缺少 Upload-Offset", http.MethodPatch, location, map[string]string{"Content-Type": tusOffsetType}, http.StatusBadRequest},
+		{"ID 无效", http.MethodHead, "/uploads/../../etc", nil, http.StatusNotFound},
+		{"上传不存在", http.MethodHead, "/uploads/" + strings.Repeat("0", 32), nil, http.StatusNotFound},
+		{"不支持的方法", http.MethodGet, location, nil, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if w := tusRequest(t, tt.method, tt.path, tt.headers, ""); w.Code != tt.want {
+			t.Errorf("%s: %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	w := tusRequest(t, http.MethodOptions, "/uploads", nil, "")
+	if w.Code != http.StatusNoContent || w.Header().Get("Tus-Max-Size") != strconv.Itoa(1<<20) {
+		t.Errorf("OPTIONS: %d, Tus-Max-Size %s", w.Code, w.Header().Get("Tus-Max-Size"))
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"linker-upgrader/upgrader"
+)
+
+// 升级本程序的配置
+type SelfUpdateConfig struct {
+	ReadyTimeout int    `json:"ready_timeout"` // 等待新进程就绪的时间（秒），默认 30
+	PublicKey    string `json:"public_key"`    // base64 编码的 ed25519 公钥，配置后要求 signature 字段
+}
+
+const (
+	// 新进程就绪后向该文件描述符写入 ready 并关闭
+	envReadyFD = "LINKER_UPGRADER_READY_FD"
+	// 运行新程序 -version 的超时时间
+	selfCheckTimeout = 10 * time.Second
+	// 交接后旧进程等待进行中请求完成的时间
+	handoverDrainTimeout = 30 * time.Second
+)
+
+var (
+	// 新程序未通过校验
+	errInvalidBinary = errors.New("新程序校验失败")
+	// 同一时间只允许一次自更新
+	selfUpdateMu sync.Mutex
+)
+
+// 升级本程序：POST /self-update（页面表单）或 /api/self-update。
+// 新程序来自 file、upload_id 或 url 字段，可选 sha256 和 signature（对 SHA256 摘要的 ed25519 签名）。
+// 校验后放在 os.Executable() 旁边，原子替换并启动新进程，监听 socket 直接传递给新进程；
+// 新进程就绪后旧进程退出，未能就绪时恢复旧程序并继续运行
+func selfUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if !selfUpdateMu.TryLock() {
+		respondSelfUpdate(w, r, http.StatusConflict, "正在升级本程序，请稍后再试", false)
+		return
+	}
+	defer selfUpdateMu.Unlock()
+	if name := builtinProfile(); name != "" {
+		respondSelfUpdate(w, r, http.StatusConflict, selfUpdateBuiltinMessage(name), false)
+		return
+	}
+
+	form, code, err := readUploadForm(w, r, appConfig.MaxFileSize<<20)
+	if errors.Is(err, errUploadCSRF) {
+		rejectCSRF(w, r)
+		return
+	}
+	if err != nil {
+		respondSelfUpdate(w, r, code, "升级本程序失败："+err.Error(), false)
+		return
+	}
+	// 新程序复制到程序目录，上传或下载的文件不再需要
+	defer form.discard()
+	source, code, err := form.source(r.Context(), r.RemoteAddr)
+	if err != nil {
+		respondSelfUpdate(w, r, code, "升级本程序失败："+err.Error(), false)
+		return
+	}
+
+	log.Printf("升级本程序: %s, SHA256: %s (来自 %s)", source.Filename, source.SHA256, r.RemoteAddr)
+	exe, staged, version, err := stageBinary(source, form.value("signature"))
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, errInvalidBinary) {
+			code = http.StatusBadRequest
+		}
+		log.Printf("升级本程序失败: %v", err)
+		respondSelfUpdate(w, r, code, "升级本程序失败："+err.Error(), false)
+		return
+	}
+	if err := handover(exe, staged); err != nil {
+		os.Remove(staged)
+		log.Printf("升级本程序失败: %v", err)
+		respondSelfUpdate(w, r, errorStatus(err), "升级本程序失败："+err.Error(), false)
+		return
+	}
+
+	respondSelfUpdate(w, r, http.StatusOK, fmt.Sprintf("已升级到 %s，新进程已接管服务", version), true)
+	// 响应发送后停止接受连接，等待进行中的请求完成后退出
+	go exitAfterHandover()
+}
+
+// 使用内置守护并启用服务管理的配置档。内置守护的进程是本程序的子进程，
+// 交接时会随旧进程停止，由新进程重新启动，因此这时不允许升级本程序
+func builtinProfile() string {
+	for _, p := range engine.Profiles() {
+		if p.ServiceEnabled() && p.ServiceManager == upgrader.ServiceManagerBuiltin {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+func selfUpdateBuiltinMessage(profile string) string {
+	return fmt.Sprintf("配置档 %s 使用内置守护 (service_manager: builtin)，升级本程序会重启它管理的程序，不能在线升级；请替换程序文件后手动重启", profile)
+}
+
+func respondSelfUpdate(w http.ResponseWriter, r *http.Request, code int, message string, success bool) {
+	if wantsJSON(r) {
+		writeJSON(w, code, map[string]any{"success": success, "message": message})
+		return
+	}
+	messageType := "error"
+	if success {
+		messageType = "success"
+	}
+	showResult(w, r, code, engine.Profile(""), message, messageType, "")
+}
+
+// 校验新程序并写入 <程序路径>.new，运行 -version 确认可以在本机执行，返回程序路径、暂存路径和新版本号
+func stageBinary(source *uploadMeta, signature string) (exe, staged, version string, err error) {
+	if appConfig.SelfUpdate.PublicKey != "" {
+		if err := verifySignature(appConfig.SelfUpdate.PublicKey, source.SHA256, signature); err != nil {
+			return "", "", "", err
+		}
+	}
+	src, err := os.Open(source.path())
+	if err != nil {
+		return "", "", "", err
+	}
+	defer src.Close()
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(src, header); err != nil || !bytes.Equal(header, []byte("\x7fELF")) {
+		return "", "", "", fmt.Errorf("%w: 不是 ELF 可执行文件", errInvalidBinary)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", "", "", err
+	}
+
+	if exe, err = os.Executable(); err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		return "", "", "", fmt.Errorf("获取程序路径失败: %v", err)
+	}
+	// 与程序位于同一目录，保证可以原子重命名
+	staged = exe + ".new"
+	dst, err := os.OpenFile(staged, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return "", "", "", fmt.Errorf("写入新程序失败: %v", err)
+	}
+	_, err = io.Copy(dst, src)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(staged)
+		return "", "", "", fmt.Errorf("写入新程序失败: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), selfCheckTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, staged, "-version").Output()
+	version = strings.TrimSpace(string(out))
+	if err != nil || version == "" {
+		os.Remove(staged)
+		return "", "", "", fmt.Errorf("%w: 新程序无法运行 (-version): %v", errInvalidBinary, err)
+	}
+	log.Printf("新程序已暂存: %s (版本 %s)", staged, version)
+	return exe, staged, version, nil
+}
+
+// 校验对 SHA256 摘要的 ed25519 签名
+func verifySignature(publicKey, sha256Hex, signature string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("公钥无效，应为 base64 编码的 ed25519 公钥")
+	}
+	if signature == "" {
+		return fmt.Errorf("%w: 缺少签名", errInvalidBinary)
+	}
+	digest, _ := hex.DecodeString(sha256Hex)
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(key, digest, sig) {
+		return fmt.Errorf("%w: 签名校验失败", errInvalidBinary)
+	}
+	return nil
+}
+
+// 暂停升级并停止后台任务，替换程序后启动新进程；新进程未能就绪时恢复旧程序和后台任务
+func handover(exe, staged string) error {
+	if err := engine.Pause(); err != nil {
+		return err
+	}
+	backup := exe + ".old"
+	os.Remove(backup)
+	if err := os.Link(exe, backup); err != nil {
+		engine.Resume()
+		return fmt.Errorf("备份当前程序失败: %v", err)
+	}
+	if err := os.Rename(staged, exe); err != nil {
+		engine.Resume()
+		return fmt.Errorf("替换程序失败: %v", err)
+	}
+	log.Printf("已替换程序: %s，旧程序保存为 %s", exe, backup)
+
+	// 确认计时、MQTT 和发布源由新进程接管，使用内置守护时不会执行到这里
+	stopBackground()
+	cmd, ready, err := startSuccessor(exe)
+	if err == nil {
+		log.Printf("新进程已启动 (PID %d)，等待就绪", cmd.Process.Pid)
+		err = waitSuccessor(cmd, ready)
+	}
+	if err != nil {
+		if restoreErr := os.Rename(backup, exe); restoreErr != nil {
+			log.Printf("恢复旧程序失败: %v", restoreErr)
+		} else {
+			log.Printf("已恢复旧程序: %s", exe)
+		}
+		startBackground()
+		engine.Resume()
+		return fmt.Errorf("新进程未能启动，已恢复旧程序: %v", err)
+	}
+
+	log.Printf("新进程已就绪 (PID %d)", cmd.Process.Pid)
+	if err := sdNotify(fmt.Sprintf("MAINPID=%d", cmd.Process.Pid)); err != nil {
+		log.Printf("通知 systemd 新的主进程失败: %v", err)
+	}
+	return nil
+}
+
+func stopBackground() {
+	if autoUpdater != nil {
+		autoUpdater.close()
+	}
+	if mqttClient != nil {
+		mqttClient.close()
+	}
+	engine.Close()
+}
+
+func startBackground() {
+	engine.Start()
+	var err error
+	if appConfig.MQTT != nil && appConfig.MQTT.Broker != "" {
+		if mqttClient, err = startMQTT(appConfig.MQTT); err != nil {
+			log.Printf("启动 MQTT 客户端失败: %v", err)
+		}
+	}
+	if appConfig.Feed != nil && appConfig.Feed.URL != "" {
+		if autoUpdater, err = startFeed(appConfig.Feed); err != nil {
+			log.Printf("启动发布源自动升级失败: %v", err)
+		}
+	}
+}
+
+// 以相同的参数启动新程序，监听按 systemd socket 激活的方式传递，最后一个文件描述符为就绪管道。
+// 返回的通道在新进程就绪（true）或退出（false）时收到结果
+func startSuccessor(exe string) (*exec.Cmd, <-chan bool, error) {
+	var files []*os.File
+	var names []string
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+		for _, l := range httpListeners {
+			restoreNonblock(l)
+		}
+		for _, l := range grpcListeners {
+			restoreNonblock(l)
+		}
+	}()
+	for _, group := range []struct {
+		name      string
+		listeners []net.Listener
+	}{{"http", httpListeners}, {"grpc", grpcListeners}} {
+		for _, l := range group.listeners {
+			f, err := listenerFile(l)
+			if err != nil {
+				return nil, nil, err
+			}
+			files = append(files, f)
+			names = append(names, group.name)
+		}
+	}
+
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	files = append(files, readyWriter)
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(os.Environ(),
+		"LISTEN_FDS="+strconv.Itoa(len(names)),
+		"LISTEN_FDNAMES="+strings.Join(names, ":"),
+		envHandoverParent+"="+strconv.Itoa(os.Getpid()),
+		envReadyFD+"="+strconv.Itoa(listenFDsStart+len(names)),
+	)
+	if err := cmd.Start(); err != nil {
+		readyReader.Close()
+		return nil, nil, err
+	}
+	// 只有新进程持有写端，新进程退出时读取立即结束
+	ready := make(chan bool, 1)
+	go func() {
+		defer readyReader.Close()
+		data, _ := io.ReadAll(readyReader)
+		ready <- string(data) == "ready"
+	}()
+	return cmd, ready, nil
+}
+
+// 等待新进程就绪；超时或退出时终止新进程
+func waitSuccessor(cmd *exec.Cmd, ready <-chan bool) error {
+	timeout := time.Duration(appConfig.SelfUpdate.ReadyTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	var err error
+	select {
+	case ok := <-ready:
+		if ok {
+			// 新进程退出后由 init 回收，这里不再等待
+			go cmd.Wait()
+			return nil
+		}
+		err = fmt.Errorf("新进程退出")
+	case <-time.After(timeout):
+		err = fmt.Errorf("等待新进程就绪超时 (%v)", timeout)
+	}
+
+	// 先请求新进程正常退出，以便其停止已启动的程序
+	cmd.Process.Signal(syscall.SIGTERM)
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case waitErr := <-done:
+		if waitErr != nil {
+			err = fmt.Errorf("%v: %v", err, waitErr)
+		}
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		<-done
+	}
+	return err
+}
+
+// 交接完成：停止接受连接（Unix socket 文件由新进程继续使用），等待进行中的请求完成后退出
+func exitAfterHandover() {
+	for _, l := range append(httpListeners, grpcListeners...) {
+		if u, ok := l.(*net.UnixListener); ok {
+			u.SetUnlinkOnClose(false)
+		}
+	}
+	closeListeners(grpcListeners)
+	ctx, cancel := context.WithTimeout(context.Background(), handoverDrainTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("等待进行中的请求超时: %v", err)
+	}
+	log.Printf("已交接给新进程，旧进程退出")
+	os.Exit(0)
+}
+
+// 由自更新启动时返回就绪管道，并避免传递给服务管理启动的子进程
+func handoverReadyPipe() *os.File {
+	fd, err := strconv.Atoi(os.Getenv(envReadyFD))
+	os.Unsetenv(envReadyFD)
+	if err != nil || fd < listenFDsStart {
+		return nil
+	}
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), "ready")
+}
+
+// 开始提供服务后通知 systemd（Type=notify）和启动本进程的旧进程
+func notifyReady(pipe *os.File) {
+	if err := sdNotify("READY=1"); err != nil {
+		log.Printf("通知 systemd 就绪失败: %v", err)
+	}
+	if pipe != nil {
+		pipe.Write([]byte("ready"))
+		pipe.Close()
+		log.Printf("已接管旧进程的监听")
+	}
+}
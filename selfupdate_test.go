@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"linker-upgrader/upgrader"
+)
+
+func TestRespondSelfUpdate(t *testing.T) {
+	setupTestEngine(t)
+
+	// 页面响应：状态码与会话 Cookie 都要发送
+	w := httptest.NewRecorder()
+	respondSelfUpdate(w, httptest.NewRequest(http.MethodPost, "/self-update", nil), http.StatusBadRequest, "升级本程序失败", false)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("code = %d", w.Code)
+	}
+	if !strings.Contains(w.Header().Get("Set-Cookie"), sessionCookieName+"=") {
+		t.Errorf("缺少会话 Cookie: %v", w.Header())
+	}
+	if !strings.Contains(w.Body.String(), "升级本程序失败") {
+		t.Error("页面缺少结果消息")
+	}
+
+	w = httptest.NewRecorder()
+	respondSelfUpdate(w, httptest.NewRequest(http.MethodPost, "/api/self-update", nil), http.StatusConflict, "忙", false)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"success":false`) {
+		t.Errorf("API: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestSelfUpdateBuiltin(t *testing.T) {
+	setupTestEngine(t)
+	appConfig.APIToken = "token"
+	appConfig.EnableService = true
+	appConfig.ServiceManager = upgrader.ServiceManagerBuiltin
+	appConfig.Process = &upgrader.ProcessConfig{Command: "/bin/true"}
+	var err error
+	if engine, err = upgrader.New(appConfig.Config); err != nil {
+		t.Fatal(err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "linker-upgrader")
+	part.Write([]byte("\x7fELF"))
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/api/self-update", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	selfUpdateHandler(w, r)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "内置守护") {
+		t.Errorf("使用内置守护时应拒绝: %d %s", w.Code, w.Body)
+	}
+
+	// 页面不显示升级表单
+	w = httptest.NewRecorder()
+	showResult(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, engine.Profile(""), "", "", "")
+	if strings.Contains(w.Body.String(), `action="/self-update"`) || !strings.Contains(w.Body.String(), "升级本程序不可用") {
+		t.Error("使用内置守护时页面不应显示升级本程序的表单")
+	}
+}
+
+func TestSelfUpdateSignatureField(t *testing.T) {
+	setupTestEngine(t)
+	for _, key := range []string{"", "a2V5"} {
+		appConfig.SelfUpdate.PublicKey = key
+		w := httptest.NewRecorder()
+		showResult(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, engine.Profile(""), "", "", "")
+		if got := strings.Contains(w.Body.String(), `name="signature"`); got != (key != "") {
+			t.Errorf("public_key %q: 签名输入框 = %v", key, got)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package upgrader
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 审计记录，按行追加写入 JSON
+type AuditEntry struct {
+	Time        time.Time `json:"time"`
+	Action      string    `json:"action"`
+	Profile     string    `json:"profile,omitempty"`
+	Filename    string    `json:"filename,omitempty"`
+	FromVersion string    `json:"from_version,omitempty"`
+	ToVersion   string    `json:"to_version,omitempty"`
+	Kind        string    `json:"kind,omitempty"`
+	Policy      string    `json:"policy,omitempty"`
+	Force       bool      `json:"force"`
+	Remote      string    `json:"remote,omitempty"`
+	Result      string    `json:"result"`
+	Message     string    `json:"message,omitempty"`
+}
+
+func (e *Engine) auditLogPath() string {
+	return filepath.Join(e.config.StateDir, "audit.log")
+}
+
+// 写入审计日志，失败时只记录到程序日志
+func (e *Engine) writeAudit(entry AuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("序列化审计记录失败: %v", err)
+		return
+	}
+
+	e.auditMu.Lock()
+	defer e.auditMu.Unlock()
+
+	if err := os.MkdirAll(e.config.StateDir, ParsePermission(e.config.DirPermission)); err != nil {
+		log.Printf("创建状态目录失败: %v", err)
+		return
+	}
+
+	f, err := os.OpenFile(e.auditLogPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("打开审计日志失败: %v", err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("写入审计日志失败: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package upgrader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 发布渠道不在配置档允许的列表中
+var ErrInvalidChannel = errors.New("发布渠道无效")
+
+// 默认发布渠道
+const DefaultChannel = "stable"
+
+var defaultChannels = []string{DefaultChannel, "beta", "dev"}
+
+// 在界面或接口中切换的发布渠道，保存在状态目录，优先于配置文件
+type channelRecord struct {
+	Channel   string    `json:"channel"`
+	ChangedAt time.Time `json:"changed_at"`
+	Remote    string    `json:"remote,omitempty"`
+}
+
+func (p *Profile) channelStatePath() string {
+	return filepath.Join(p.stateDir(), "channel.json")
+}
+
+// 当前跟随的发布渠道
+func (p *Profile) ActiveChannel() string {
+	p.deployMu.RLock()
+	defer p.deployMu.RUnlock()
+	if p.channel != "" {
+		return p.channel
+	}
+	return p.Channel
+}
+
+// 启动时加载切换过的发布渠道，已不在允许列表中的渠道被忽略
+func (p *Profile) loadChannel() {
+	data, err := os.ReadFile(p.channelStatePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[%s] 读取发布渠道失败: %v", p.Name, err)
+		}
+		return
+	}
+
+	var record channelRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		log.Printf("[%s] 解析发布渠道失败: %v", p.Name, err)
+		return
+	}
+	if !slices.Contains(p.Channels, record.Channel) {
+		log.Printf("[%s] 发布渠道 %s 不在允许的列表中，使用配置的 %s", p.Name, record.Channel, p.Channel)
+		return
+	}
+
+	p.deployMu.Lock()
+	p.channel = record.Channel
+	p.deployMu.Unlock()
+}
+
+// 切换发布渠道。只改变之后拉取的发布，不会立即安装任何版本
+func (e *Engine) SetChannel(name, channel, remote string) error {
+	p, err := e.lookup(name)
+	if err != nil {
+		return err
+	}
+	channel = strings.TrimSpace(channel)
+	if !slices.Contains(p.Channels, channel) {
+		return fmt.Errorf("%w: %s，允许的渠道: %s", ErrInvalidChannel, channel, strings.Join(p.Channels, ", "))
+	}
+
+	previous := p.ActiveChannel()
+	record := &channelRecord{Channel: channel, ChangedAt: time.Now(), Remote: remote}
+	if err := writeJSONFile(p.channelStatePath(), record, ParsePermission(p.DirPermission)); err != nil {
+		return fmt.Errorf("保存发布渠道失败: %v", err)
+	}
+	p.deployMu.Lock()
+	p.channel = channel
+	p.deployMu.Unlock()
+
+	log.Printf("[%s] 发布渠道: %s -> %s (来自 %s)", p.Name, previous, channel, remote)
+	e.writeAudit(AuditEntry{
+		Action:  "channel",
+		Profile: p.Name,
+		Remote:  remote,
+		Result:  "success",
+		Message: fmt.Sprintf("%s -> %s", previous, channel),
+	})
+	return nil
+}
@@ -0,0 +1,122 @@
+package upgrader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 各步骤外部命令的超时（秒）
+type CommandTimeouts struct {
+	Stop    int `json:"stop"`    // 停止服务，超时后强制终止
+	Start   int `json:"start"`   // 启动服务
+	Backup  int `json:"backup"`  // 打包备份
+	Deploy  int `json:"deploy"`  // 解压部署、从备份恢复
+	Command int `json:"command"` // 其他命令：状态查询、版本命令、日志读取等
+}
+
+// 升级日志中最多保留的单条命令输出行数
+const maxCommandLogLines = 100
+
+func defaultCommandTimeouts() CommandTimeouts {
+	return CommandTimeouts{Stop: 30, Start: 60, Backup: 600, Deploy: 600, Command: 15}
+}
+
+// 未配置的超时使用 fallback 中的值
+func (t *CommandTimeouts) applyDefaults(fallback CommandTimeouts) {
+	if t.Stop <= 0 {
+		t.Stop = fallback.Stop
+	}
+	if t.Start <= 0 {
+		t.Start = fallback.Start
+	}
+	if t.Backup <= 0 {
+		t.Backup = fallback.Backup
+	}
+	if t.Deploy <= 0 {
+		t.Deploy = fallback.Deploy
+	}
+	if t.Command <= 0 {
+		t.Command = fallback.Command
+	}
+}
+
+func withTimeout(ctx context.Context, seconds int) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+}
+
+type commandLogKey struct{}
+
+// 在 ctx 中附带升级日志，之后通过 runCommand 执行的命令输出都会写入该日志
+func withCommandLog(ctx context.Context, logs *strings.Builder) context.Context {
+	return context.WithValue(ctx, commandLogKey{}, logs)
+}
+
+func commandLog(ctx context.Context) *strings.Builder {
+	logs, _ := ctx.Value(commandLogKey{}).(*strings.Builder)
+	return logs
+}
+
+// 创建外部命令：独立进程组，ctx 取消或超时时终止整个进程组
+func newCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	// 命令启动的后台进程持有输出管道时不无限等待
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// 执行外部命令，合并的 stdout/stderr 写入 ctx 中的升级日志
+func runCommand(ctx context.Context, name string, args ...string) error {
+	var out bytes.Buffer
+	cmd := newCommand(ctx, name, args...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+
+	if logs := commandLog(ctx); logs != nil {
+		writeCommandOutput(logs, out.String())
+	}
+	return commandError(ctx, name, err, out.String())
+}
+
+// 将命令输出缩进写入日志，过长时只保留最后若干行
+func writeCommandOutput(logs *strings.Builder, output string) {
+	output = strings.TrimRight(output, "\n")
+	if strings.TrimSpace(output) == "" {
+		return
+	}
+	lines := strings.Split(output, "\n")
+	if over := len(lines) - maxCommandLogLines; over > 0 {
+		logs.WriteString(fmt.Sprintf("   | ... (省略 %d 行)\n", over))
+		lines = lines[over:]
+	}
+	for _, line := range lines {
+		logs.WriteString("   | " + line + "\n")
+	}
+}
+
+// 区分超时、取消与普通失败，普通失败时附带输出的最后一行
+func commandError(ctx context.Context, name string, err error, output string) error {
+	if err == nil || errors.Is(err, exec.ErrWaitDelay) {
+		return nil
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%s 执行超时，已终止", name)
+	case context.Canceled:
+		return fmt.Errorf("%s 已取消", name)
+	}
+	if msg := strings.TrimSpace(output); msg != "" {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(lastLines(msg, 1)))
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package upgrader
+
+// 升级引擎配置，未配置 Profiles 时由顶层的目录与服务配置生成 default 配置档
+type Config struct {
+	// 目录配置
+	TargetDir string `json:"target_dir"`
+	BackupDir string `json:"backup_dir"`
+
+	// 服务配置
+	ServiceName string `json:"service_name"`
+	// 服务管理后端: systemd / openrc / sysv / supervisor / custom / builtin
+	ServiceManager  string           `json:"service_manager"`
+	ServiceCommands *ServiceCommands `json:"service_commands,omitempty"` // custom 后端使用
+	Process         *ProcessConfig   `json:"process,omitempty"`          // builtin 后端使用
+	HealthChecks    []HealthCheck    `json:"health_checks,omitempty"`    // 启动后的健康检查
+	FailureLogLines int              `json:"failure_log_lines"`          // 启动失败时附带的服务日志行数
+
+	// 升级后观察期
+	ObserveWindow      int    `json:"observe_window"`       // 秒，0 表示不观察
+	CrashLoopThreshold int    `json:"crash_loop_threshold"` // 观察期内允许的重启次数
+	RollbackOnFailure  bool   `json:"rollback_on_failure"`  // 健康检查或观察期失败时自动回滚
+	ConfirmTimeout     int    `json:"confirm_timeout"`      // 秒，大于 0 时新版本需应用确认，超时自动恢复
+	ConfirmToken       string `json:"confirm_token"`        // 应用确认使用的令牌，只能用于本配置档的确认接口
+
+	// 外部命令超时
+	Timeouts CommandTimeouts `json:"timeouts"`
+
+	// 升级流程：步骤名称列表，可包含 custom_steps 中定义的步骤
+	Steps       []string     `json:"steps"`
+	CustomSteps []CustomStep `json:"custom_steps,omitempty"`
+
+	// 功能开关
+	EnableBackup  bool `json:"enable_backup"`
+	EnableService bool `json:"enable_service"`
+
+	// 权限配置
+	DirPermission  string `json:"dir_permission"`
+	FilePermission string `json:"file_permission"`
+	ExecPermission string `json:"exec_permission"`
+
+	// 版本配置
+	StateDir       string   `json:"state_dir"`       // 部署记录等状态文件目录
+	ManifestFile   string   `json:"manifest_file"`   // 相对目标目录的清单文件
+	VersionCommand []string `json:"version_command"` // 输出版本号的命令
+
+	// 版本策略: allow / warn / deny
+	DowngradePolicy   string `json:"downgrade_policy"`
+	SameVersionPolicy string `json:"same_version_policy"`
+
+	// 发布源拉取升级时跟随的渠道，以及允许切换到的渠道
+	Channel  string   `json:"channel"`
+	Channels []string `json:"channels,omitempty"`
+
+	// 多程序配置档，未配置时使用上面的目录与服务配置
+	Profiles     []ProfileConfig `json:"profiles,omitempty"`
+	HistoryLimit int             `json:"history_limit"` // 每个配置档保留的升级历史条数
+
+	// 配置档默认说明与接受的文件类型
+	Description string   `json:"description"`
+	AcceptTypes []string `json:"accept_types"`
+}
+
+// 默认配置
+func DefaultConfig() Config {
+	return Config{
+		TargetDir:         "/opt/myapp",
+		BackupDir:         "/opt/myapp/backup",
+		ServiceName:       "myapp",
+		ServiceManager:    ServiceManagerSystemd,
+		EnableBackup:      true,
+		EnableService:     true,
+		DirPermission:     "0755",
+		FilePermission:    "0644",
+		ExecPermission:    "0755",
+		StateDir:          "./state",
+		ManifestFile:      "manifest.json",
+		DowngradePolicy:   PolicyWarn,
+		SameVersionPolicy: PolicyWarn,
+		Channel:           DefaultChannel,
+		HistoryLimit:      50,
+		FailureLogLines:   50,
+		Timeouts:          defaultCommandTimeouts(),
+		Steps:             defaultSteps,
+		Description:       "支持 .tar.gz, .zip, 可执行文件的程序升级系统",
+		AcceptTypes:       []string{".tar.gz", ".zip", ".gz", "application/x-executable", "text/x-shellscript"},
+	}
+}
+
+// 为旧版本配置文件中缺失的新字段填充默认值
+func (c *Config) ApplyDefaults() {
+	if c.StateDir == "" {
+		c.StateDir = "./state"
+	}
+	if c.ManifestFile == "" {
+		c.ManifestFile = "manifest.json"
+	}
+	if c.DowngradePolicy == "" {
+		c.DowngradePolicy = PolicyWarn
+	}
+	if c.SameVersionPolicy == "" {
+		c.SameVersionPolicy = PolicyWarn
+	}
+	if c.Channel == "" {
+		c.Channel = DefaultChannel
+	}
+	if len(c.Channels) == 0 {
+		c.Channels = defaultChannels
+	}
+	if c.ServiceManager == "" {
+		c.ServiceManager = ServiceManagerSystemd
+	}
+	if c.FailureLogLines == 0 {
+		c.FailureLogLines = 50
+	}
+	if c.HistoryLimit == 0 {
+		c.HistoryLimit = 50
+	}
+	c.Timeouts.applyDefaults(defaultCommandTimeouts())
+	if len(c.Steps) == 0 {
+		c.Steps = defaultSteps
+	}
+}
@@ -0,0 +1,179 @@
+package upgrader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 待确认的升级：应用在截止时间前调用确认接口，否则自动恢复到升级前的版本
+type PendingUpgrade struct {
+	Version    string        `json:"version"`
+	Filename   string        `json:"filename"`
+	BackupPath string        `json:"backup_path"`
+	Previous   *DeployRecord `json:"previous,omitempty"`
+	DeployedAt time.Time     `json:"deployed_at"`
+	Deadline   time.Time     `json:"deadline"`
+}
+
+// 每个配置档的确认状态
+type confirmState struct {
+	mu      sync.Mutex
+	pending *PendingUpgrade
+	timer   *time.Timer
+}
+
+func (p *Profile) pendingPath() string {
+	return filepath.Join(p.stateDir(), "pending.json")
+}
+
+// 当前待确认的升级（可能为 nil）
+func (p *Profile) Pending() *PendingUpgrade {
+	p.confirm.mu.Lock()
+	defer p.confirm.mu.Unlock()
+	return p.confirm.pending
+}
+
+// 升级成功后进入待确认状态，状态写入磁盘以便升级器重启后继续计时
+func (p *Profile) setPending(pending *PendingUpgrade) error {
+	if err := writeJSONFile(p.pendingPath(), pending, ParsePermission(p.DirPermission)); err != nil {
+		return fmt.Errorf("保存待确认状态失败: %v", err)
+	}
+
+	p.confirm.mu.Lock()
+	defer p.confirm.mu.Unlock()
+	p.confirm.pending = pending
+	p.scheduleRevertLocked(pending)
+	return nil
+}
+
+// 清除待确认状态
+func (p *Profile) clearPending() {
+	p.confirm.mu.Lock()
+	defer p.confirm.mu.Unlock()
+	p.clearPendingLocked()
+}
+
+func (p *Profile) clearPendingLocked() {
+	if p.confirm.timer != nil {
+		p.confirm.timer.Stop()
+		p.confirm.timer = nil
+	}
+	p.confirm.pending = nil
+	if err := os.Remove(p.pendingPath()); err != nil && !os.IsNotExist(err) {
+		log.Printf("[%s] 删除待确认状态失败: %v", p.Name, err)
+	}
+}
+
+// 停止确认计时，待确认状态保留在磁盘上
+func (p *Profile) stopConfirmTimer() {
+	p.confirm.mu.Lock()
+	defer p.confirm.mu.Unlock()
+	if p.confirm.timer != nil {
+		p.confirm.timer.Stop()
+		p.confirm.timer = nil
+	}
+}
+
+func (p *Profile) scheduleRevertLocked(pending *PendingUpgrade) {
+	if p.confirm.timer != nil {
+		p.confirm.timer.Stop()
+	}
+	p.confirm.timer = time.AfterFunc(time.Until(pending.Deadline), func() {
+		p.revertUnconfirmed(pending)
+	})
+}
+
+// 启动时恢复待确认状态，已超时的立即回滚
+func (p *Profile) loadPending() {
+	data, err := os.ReadFile(p.pendingPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[%s] 读取待确认状态失败: %v", p.Name, err)
+		}
+		return
+	}
+
+	var pending PendingUpgrade
+	if err := json.Unmarshal(data, &pending); err != nil {
+		log.Printf("[%s] 解析待确认状态失败: %v", p.Name, err)
+		return
+	}
+
+	log.Printf("[%s] 版本 %s 等待应用确认，截止 %s", p.Name, displayVersion(pending.Version), pending.Deadline.Format("2006-01-02 15:04:05"))
+
+	p.confirm.mu.Lock()
+	defer p.confirm.mu.Unlock()
+	p.confirm.pending = &pending
+	p.scheduleRevertLocked(&pending)
+}
+
+// 应用确认新版本
+func (p *Profile) confirmUpgrade(version, remote string) error {
+	p.confirm.mu.Lock()
+	defer p.confirm.mu.Unlock()
+
+	pending := p.confirm.pending
+	if pending == nil {
+		return fmt.Errorf("没有等待确认的升级")
+	}
+	if version != "" && pending.Version != "" && version != pending.Version {
+		return fmt.Errorf("确认的版本 %s 与待确认版本 %s 不一致", version, pending.Version)
+	}
+
+	p.clearPendingLocked()
+	p.engine.writeAudit(AuditEntry{Action: "confirm", Profile: p.Name, Filename: pending.Filename, ToVersion: pending.Version, Remote: remote, Result: "success"})
+	log.Printf("[%s] 版本 %s 已由应用确认", p.Name, displayVersion(pending.Version))
+	return nil
+}
+
+// 截止时间到达仍未确认：恢复升级前的备份
+func (p *Profile) revertUnconfirmed(pending *PendingUpgrade) {
+	// 等待正在进行的升级或服务操作结束
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.confirm.mu.Lock()
+	if p.confirm.pending != pending {
+		// 已确认或已被新的升级取代
+		p.confirm.mu.Unlock()
+		return
+	}
+	p.clearPendingLocked()
+	p.confirm.mu.Unlock()
+
+	log.Printf("[%s] 版本 %s 未在截止时间前确认，自动恢复", p.Name, displayVersion(pending.Version))
+
+	var logs strings.Builder
+	audit := AuditEntry{Action: "auto_revert", Profile: p.Name, Filename: pending.Filename, FromVersion: pending.Version, Result: "success", Message: "未在截止时间前确认"}
+	if pending.Previous != nil {
+		audit.ToVersion = pending.Previous.Version
+	}
+
+	err := fmt.Errorf("没有可用的备份")
+	if pending.BackupPath != "" {
+		ctx := withCommandLog(context.Background(), &logs)
+		err = p.restoreBackup(ctx, pending.BackupPath, pending.Previous, &logs)
+	}
+	if err != nil {
+		audit.Result = "failed"
+		audit.Message = fmt.Sprintf("未在截止时间前确认，恢复失败: %v", err)
+		log.Printf("[%s] 自动恢复失败: %v", p.Name, err)
+	}
+	p.engine.writeAudit(audit)
+
+	p.addHistory(HistoryEntry{
+		Time:        time.Now(),
+		Filename:    pending.Filename,
+		FromVersion: audit.FromVersion,
+		ToVersion:   audit.ToVersion,
+		Result:      "reverted",
+		Message:     audit.Message,
+	})
+}
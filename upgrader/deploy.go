@@ -0,0 +1,163 @@
+package upgrader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 升级包文件名不能为空，也不能包含路径或指向上级目录
+func checkFilename(name string) error {
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return fmt.Errorf("%w: %q", ErrInvalidFilename, name)
+	}
+	return nil
+}
+
+func deployProgram(ctx context.Context, p *Profile, filePath, filename string, logs *strings.Builder) error {
+	ctx, cancel := withTimeout(ctx, p.Timeouts.Deploy)
+	defer cancel()
+
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	switch ext {
+	case ".gz":
+		if strings.HasSuffix(strings.ToLower(filename), ".tar.gz") {
+			// tar.gz 文件
+			logs.WriteString("   解压 tar.gz 文件...\n")
+			if err := runCommand(ctx, "tar", "-xzf", filePath, "-C", p.TargetDir); err != nil {
+				return fmt.Errorf("解压 tar.gz 失败: %v", err)
+			}
+		} else {
+			// 单个 .gz 文件
+			logs.WriteString("   解压 gz 文件...\n")
+			outputPath := filepath.Join(p.TargetDir, strings.TrimSuffix(filename, ".gz"))
+			if err := gunzipFile(ctx, filePath, outputPath); err != nil {
+				return fmt.Errorf("解压 gz 文件失败: %v", err)
+			}
+		}
+	case ".zip":
+		logs.WriteString("   解压 zip 文件...\n")
+		if err := runCommand(ctx, "unzip", "-o", filePath, "-d", p.TargetDir); err != nil {
+			return fmt.Errorf("解压 zip 失败: %v", err)
+		}
+	default:
+		// 直接复制可执行文件
+		logs.WriteString("   复制可执行文件...\n")
+		targetPath := filepath.Join(p.TargetDir, filename)
+		if err := copyFile(filePath, targetPath); err != nil {
+			return fmt.Errorf("复制文件失败: %v", err)
+		}
+	}
+
+	logs.WriteString("   ✓ 程序部署完成\n")
+	return nil
+}
+
+// gunzip -c 解压到目标文件，失败时删除不完整的文件
+func gunzipFile(ctx context.Context, src, dst string) error {
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := newCommand(ctx, "gunzip", "-c", src)
+	cmd.Stdout = out
+	cmd.Stderr = &stderr
+	err = commandError(ctx, "gunzip", cmd.Run(), stderr.String())
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
+
+func setPermissions(p *Profile, logs *strings.Builder) error {
+	dirPerm := ParsePermission(p.DirPermission)
+	filePerm := ParsePermission(p.FilePermission)
+	execPerm := ParsePermission(p.ExecPermission)
+
+	// 遍历目录，为可执行文件设置权限
+	err := filepath.Walk(p.TargetDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// 为所有文件设置适当权限
+		if info.IsDir() {
+			os.Chmod(path, dirPerm)
+		} else {
+			// 检查是否为可执行文件
+			if isExecutable(path) {
+				os.Chmod(path, execPerm)
+				logs.WriteString(fmt.Sprintf("   ✓ 设置可执行权限 (%s): %s\n", p.ExecPermission, path))
+			} else {
+				os.Chmod(path, filePerm)
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("设置权限失败: %v", err)
+	}
+
+	return nil
+}
+
+func isExecutable(filePath string) bool {
+	// 检查文件是否为可执行文件
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	// 读取文件头部判断是否为 ELF 文件
+	header := make([]byte, 4)
+	if _, err := file.Read(header); err != nil {
+		return false
+	}
+
+	// ELF 魔数：0x7F 'E' 'L' 'F'
+	if len(header) >= 4 && header[0] == 0x7F && header[1] == 'E' && header[2] == 'L' && header[3] == 'F' {
+		return true
+	}
+
+	// 也可以检查文件扩展名
+	ext := strings.ToLower(filepath.Ext(filePath))
+	return ext == "" || ext == ".bin" || ext == ".exe"
+}
+
+func copyFile(src, dst string) error {
+	sourceFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	destFile, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer destFile.Close()
+
+	_, err = io.Copy(destFile, sourceFile)
+	return err
+}
+
+// 工具函数：将字符串权限转换为 os.FileMode
+func ParsePermission(permStr string) os.FileMode {
+	if perm, err := strconv.ParseUint(permStr, 8, 32); err == nil {
+		return os.FileMode(perm)
+	}
+	return 0755 // 默认权限
+}
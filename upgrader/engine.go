@@ -0,0 +1,465 @@
+package upgrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// 配置档不存在
+	ErrUnknownProfile = errors.New("配置档不存在")
+	// 配置档正在升级或执行其他操作
+	ErrBusy = errors.New("配置档正在升级中，请稍后再试")
+	// 版本策略禁止本次降级或重复安装
+	ErrPolicy = errors.New("版本策略禁止")
+	// 升级包文件名为空或包含路径
+	ErrInvalidFilename = errors.New("文件名无效")
+)
+
+// 升级引擎：负责所有配置档的部署、备份、回滚与服务控制
+// 引擎之间不共享任何状态，同一进程内可以创建多个引擎
+type Engine struct {
+	config   Config
+	profiles []*Profile
+	auditMu  sync.Mutex
+}
+
+// 根据配置创建引擎，并加载各配置档的部署记录
+func New(config Config) (*Engine, error) {
+	config.ApplyDefaults()
+	e := &Engine{config: config}
+
+	profiles, err := e.initProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		p.loadDeployRecord()
+		p.loadChannel()
+	}
+	e.profiles = profiles
+	return e, nil
+}
+
+// 恢复待确认的升级，并启动内置守护的进程
+func (e *Engine) Start() {
+	for _, p := range e.profiles {
+		p.loadPending()
+		if _, ok := p.svc.(*processSupervisor); ok && p.ServiceEnabled() {
+			if err := p.startService(context.Background()); err != nil {
+				log.Printf("[%s] 启动程序失败: %v", p.Name, err)
+			}
+		}
+	}
+}
+
+// 停止确认计时和内置守护的进程
+func (e *Engine) Close() {
+	var wg sync.WaitGroup
+	for _, p := range e.profiles {
+		p.stopConfirmTimer()
+		if s, ok := p.svc.(*processSupervisor); ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.Stop(context.Background(), p.ServiceName)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// 暂停所有配置档的升级、恢复和服务操作，任一配置档正忙时返回 ErrBusy
+func (e *Engine) Pause() error {
+	for i, p := range e.profiles {
+		if !p.lock.TryLock() {
+			for _, q := range e.profiles[:i] {
+				q.lock.Unlock()
+			}
+			return fmt.Errorf("%w: %s", ErrBusy, p.Name)
+		}
+	}
+	return nil
+}
+
+// 恢复 Pause 暂停的操作
+func (e *Engine) Resume() {
+	for _, p := range e.profiles {
+		p.lock.Unlock()
+	}
+}
+
+// 已加载的配置档，按配置顺序排列
+func (e *Engine) Profiles() []*Profile {
+	return e.profiles
+}
+
+// 按名称查找配置档，名称为空时返回第一个，不存在时返回 nil
+func (e *Engine) Profile(name string) *Profile {
+	if name == "" {
+		return e.profiles[0]
+	}
+	for _, p := range e.profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+func (e *Engine) lookup(name string) (*Profile, error) {
+	if p := e.Profile(name); p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+}
+
+// 事件类型
+const (
+	EventStart     = "start"      // 开始升级或恢复
+	EventStepStart = "step_start" // 步骤开始
+	EventStepEnd   = "step_end"   // 步骤结束，Status 为步骤状态，Logs 为该步骤的输出
+	EventFinish    = "finish"     // 结束，Status 为 success 或 failed
+)
+
+// 升级或恢复过程中的事件
+type Event struct {
+	Time     time.Time `json:"time"`
+	Profile  string    `json:"profile"`
+	Type     string    `json:"type"`
+	Step     string    `json:"step,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Logs     string    `json:"logs,omitempty"`
+	Duration float64   `json:"duration,omitempty"` // 秒
+}
+
+// 事件回调，在执行升级的 goroutine 中同步调用
+type EventFunc func(Event)
+
+func (f EventFunc) emit(profile string, event Event) {
+	if f == nil {
+		return
+	}
+	event.Time = time.Now()
+	event.Profile = profile
+	f(event)
+}
+
+// 升级请求
+type UpgradeRequest struct {
+	Profile  string // 为空时使用第一个配置档
+	FilePath string // 升级包路径
+	Filename string // 原始文件名，用于识别包类型和版本
+	SHA256   string // 升级包的 SHA256，为空时在记录版本时计算
+	Force    bool   // 忽略降级/重复安装策略
+	Remote   string // 发起方地址，用于审计
+}
+
+// 升级或恢复结果
+type UpgradeResult struct {
+	Profile     string        `json:"profile"`
+	Success     bool          `json:"success"`
+	Message     string        `json:"message"`
+	Logs        string        `json:"logs,omitempty"`
+	ServiceLogs string        `json:"service_logs,omitempty"` // 启动或健康检查失败时的服务输出
+	Deployed    *DeployRecord `json:"deployed,omitempty"`
+	Steps       []StepResult  `json:"steps,omitempty"`
+}
+
+// 执行升级，返回的结果始终非空
+func (e *Engine) Upgrade(ctx context.Context, req UpgradeRequest, onEvent EventFunc) (result *UpgradeResult, err error) {
+	result = &UpgradeResult{Profile: req.Profile}
+	p, err := e.lookup(req.Profile)
+	if err != nil {
+		result.Message = "升级失败：" + err.Error()
+		return result, err
+	}
+	result.Profile = p.Name
+
+	audit := AuditEntry{Action: "upgrade", Profile: p.Name, Filename: req.Filename, Force: req.Force, Remote: req.Remote}
+
+	// 同一配置档不允许并发升级
+	if !p.lock.TryLock() {
+		audit.Result = "rejected"
+		audit.Message = "配置档正在升级中"
+		e.writeAudit(audit)
+		err = fmt.Errorf("配置档 %s: %w", p.Name, ErrBusy)
+		result.Message = "升级失败：" + err.Error()
+		return result, err
+	}
+	defer p.lock.Unlock()
+
+	onEvent.emit(p.Name, Event{Type: EventStart, Message: req.Filename})
+
+	started := time.Now()
+	defer func() {
+		audit.Result = "success"
+		result.Success = err == nil
+		result.Message = "程序升级成功！"
+		result.Deployed = p.Deployed()
+		if err != nil {
+			audit.Result = "failed"
+			audit.Message = err.Error()
+			result.Message = "升级失败：" + err.Error()
+		}
+		e.writeAudit(audit)
+
+		p.addHistory(HistoryEntry{
+			Time:        started,
+			Filename:    req.Filename,
+			FromVersion: audit.FromVersion,
+			ToVersion:   audit.ToVersion,
+			Force:       req.Force,
+			Result:      audit.Result,
+			Message:     audit.Message,
+			Duration:    time.Since(started).Seconds(),
+		})
+		onEvent.emit(p.Name, Event{Type: EventFinish, Status: audit.Result, Message: result.Message, Duration: time.Since(started).Seconds()})
+	}()
+
+	result.Logs, err = p.runUpgrade(ctx, req, &audit, result, onEvent)
+	return result, err
+}
+
+func (p *Profile) runUpgrade(ctx context.Context, req UpgradeRequest, audit *AuditEntry, result *UpgradeResult, onEvent EventFunc) (string, error) {
+	var logs strings.Builder
+	ctx = withCommandLog(ctx, &logs)
+
+	logs.WriteString(fmt.Sprintf("开始升级程序: %s\n", req.Filename))
+	logs.WriteString(fmt.Sprintf("时间: %s\n", time.Now().Format("2006-01-02 15:04:05")))
+	logs.WriteString(fmt.Sprintf("配置: 配置档=%s, 目标=%s, 服务=%s (%s)\n", p.Name, p.TargetDir, p.ServiceName, p.svc.Name()))
+
+	// 文件名用于拼接部署路径，不能包含目录
+	if err := checkFilename(req.Filename); err != nil {
+		audit.Action = "upgrade_denied"
+		logs.WriteString(fmt.Sprintf("   ✗ %v\n", err))
+		return logs.String(), err
+	}
+
+	// 升级包类型检查
+	if err := p.checkFileType(req.FilePath, req.Filename); err != nil {
+		audit.Action = "upgrade_denied"
+		logs.WriteString(fmt.Sprintf("   ✗ %v\n", err))
+		return logs.String(), err
+	}
+
+	// 版本策略检查
+	decision, err := p.checkVersionPolicy(req.FilePath, req.Filename, req.Force, &logs)
+	audit.FromVersion = decision.FromVersion
+	audit.ToVersion = decision.ToVersion
+	audit.Kind = decision.Kind
+	audit.Policy = decision.Policy
+	if err != nil {
+		audit.Action = "upgrade_denied"
+		return logs.String(), err
+	}
+	if decision.Forced {
+		audit.Message = "强制覆盖版本策略"
+	}
+	if pending := p.Pending(); pending != nil {
+		logs.WriteString(fmt.Sprintf("注意: 版本 %s 尚未确认，本次升级将取代它\n", displayVersion(pending.Version)))
+		p.clearPending()
+	}
+
+	u := &upgradeRun{
+		profile:  p,
+		req:      req,
+		logs:     &logs,
+		result:   result,
+		onEvent:  onEvent,
+		previous: p.Deployed(),
+	}
+	result.Steps, err = p.runPipeline(ctx, u)
+	writeStepSummary(&logs, result.Steps)
+	if err != nil {
+		return logs.String(), err
+	}
+
+	logs.WriteString(fmt.Sprintf("\n升级完成时间: %s\n", time.Now().Format("2006-01-02 15:04:05")))
+	return logs.String(), nil
+}
+
+// 恢复请求
+type RestoreRequest struct {
+	Profile string // 为空时使用第一个配置档
+	Backup  string // 备份文件名，为空时使用最新的备份
+	Remote  string // 发起方地址，用于审计
+}
+
+// 从配置档备份目录中的备份恢复，返回的结果始终非空
+func (e *Engine) Restore(ctx context.Context, req RestoreRequest, onEvent EventFunc) (result *UpgradeResult, err error) {
+	result = &UpgradeResult{Profile: req.Profile}
+	p, err := e.lookup(req.Profile)
+	if err != nil {
+		result.Message = "恢复失败：" + err.Error()
+		return result, err
+	}
+	result.Profile = p.Name
+
+	if !p.lock.TryLock() {
+		err = fmt.Errorf("配置档 %s: %w", p.Name, ErrBusy)
+		result.Message = "恢复失败：" + err.Error()
+		return result, err
+	}
+	defer p.lock.Unlock()
+
+	backup, err := p.findBackup(req.Backup)
+	if err != nil {
+		result.Message = "恢复失败：" + err.Error()
+		return result, err
+	}
+
+	audit := AuditEntry{Action: "restore", Profile: p.Name, Filename: backup.Name, Remote: req.Remote}
+	if record := p.Deployed(); record != nil {
+		audit.FromVersion = record.Version
+	}
+	onEvent.emit(p.Name, Event{Type: EventStart, Message: backup.Name})
+
+	var logs strings.Builder
+	ctx = withCommandLog(ctx, &logs)
+	logs.WriteString(fmt.Sprintf("开始从备份恢复: %s\n", backup.Name))
+	logs.WriteString(fmt.Sprintf("时间: %s\n", time.Now().Format("2006-01-02 15:04:05")))
+
+	started := time.Now()
+	onEvent.emit(p.Name, Event{Type: EventStepStart, Step: "restore"})
+	if p.Pending() != nil {
+		logs.WriteString("注意: 取消尚未确认的升级\n")
+		p.clearPending()
+	}
+	// 备份时保存的部署记录；旧版本创建的备份没有记录，按恢复后的文件识别版本
+	previous := loadBackupRecord(backup.Path)
+	err = p.restoreBackup(ctx, backup.Path, previous, &logs)
+	if err == nil && previous == nil {
+		p.recordDeployment(ctx, backup.Path, backup.Name, "", &logs)
+	}
+
+	status := StepStatusSuccess
+	historyResult := "restored"
+	audit.Result = "success"
+	result.Message = "已从备份恢复"
+	if err != nil {
+		status = StepStatusFailed
+		historyResult = "failed"
+		audit.Result = "failed"
+		audit.Message = err.Error()
+		result.Message = "恢复失败：" + err.Error()
+		logs.WriteString(fmt.Sprintf("   ✗ %v\n", err))
+	}
+	duration := time.Since(started).Seconds()
+	onEvent.emit(p.Name, Event{Type: EventStepEnd, Step: "restore", Status: status, Logs: logs.String(), Duration: duration})
+
+	result.Success = err == nil
+	result.Logs = logs.String()
+	result.Deployed = p.Deployed()
+	result.Steps = []StepResult{{Name: "restore", Status: status, Duration: duration, Message: audit.Message}}
+	if result.Deployed != nil {
+		audit.ToVersion = result.Deployed.Version
+	}
+	e.writeAudit(audit)
+	p.addHistory(HistoryEntry{
+		Time:        started,
+		Filename:    backup.Name,
+		FromVersion: audit.FromVersion,
+		ToVersion:   audit.ToVersion,
+		Result:      historyResult,
+		Message:     audit.Message,
+		Duration:    duration,
+	})
+	onEvent.emit(p.Name, Event{Type: EventFinish, Status: audit.Result, Message: result.Message, Duration: duration})
+	return result, err
+}
+
+// 配置档状态
+type ProfileStatus struct {
+	Profile     string          `json:"profile"`
+	TargetDir   string          `json:"target_dir"`
+	ServiceName string          `json:"service_name"`
+	Upgrading   bool            `json:"upgrading"`
+	Channel     string          `json:"channel"`
+	Deployed    *DeployRecord   `json:"deployed"`
+	Pending     *PendingUpgrade `json:"pending,omitempty"`
+	Service     *ServiceStatus  `json:"service,omitempty"` // 未启用服务管理时为空
+}
+
+// 服务运行状态
+type ServiceStatus struct {
+	Manager string `json:"manager"`
+	Active  bool   `json:"active"`
+	Error   string `json:"error,omitempty"`
+}
+
+// 查询配置档状态，name 为空时返回全部配置档
+func (e *Engine) Status(ctx context.Context, name string) ([]ProfileStatus, error) {
+	list := e.profiles
+	if name != "" {
+		p, err := e.lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		list = []*Profile{p}
+	}
+
+	statuses := make([]ProfileStatus, 0, len(list))
+	for _, p := range list {
+		status := ProfileStatus{
+			Profile:     p.Name,
+			TargetDir:   p.TargetDir,
+			ServiceName: p.ServiceName,
+			Upgrading:   p.Upgrading(),
+			Channel:     p.ActiveChannel(),
+			Deployed:    p.Deployed(),
+			Pending:     p.Pending(),
+		}
+		if p.ServiceEnabled() {
+			status.Service = &ServiceStatus{Manager: p.svc.Name(), Active: true}
+			if err := p.serviceStatus(ctx); err != nil {
+				status.Service.Active = false
+				status.Service.Error = err.Error()
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// 升级历史（最新的在前）
+func (e *Engine) History(name string) ([]HistoryEntry, error) {
+	p, err := e.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.History(), nil
+}
+
+// 配置档备份目录中的备份（最新的在前）
+func (e *Engine) Backups(name string) ([]BackupInfo, error) {
+	p, err := e.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.listBackups()
+}
+
+// 应用确认待确认的升级
+func (e *Engine) Confirm(name, version, remote string) error {
+	p, err := e.lookup(name)
+	if err != nil {
+		return err
+	}
+	return p.confirmUpgrade(version, remote)
+}
+
+// 执行服务操作（start / stop / restart），升级进行中时拒绝；返回命令输出
+func (e *Engine) ControlService(ctx context.Context, name, action, remote string) (string, error) {
+	p, err := e.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	return p.controlService(ctx, action, remote)
+}
@@ -0,0 +1,139 @@
+package upgrader
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试使用的引擎：临时目录，服务由 custom 命令管理
+func newTestEngine(t *testing.T, configure func(c *Config)) *Engine {
+	t.Helper()
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.TargetDir = filepath.Join(dir, "target")
+	config.BackupDir = filepath.Join(dir, "backup")
+	config.StateDir = filepath.Join(dir, "state")
+	config.EnableService = false
+	if configure != nil {
+		configure(&config)
+	}
+	for _, d := range []string{config.TargetDir, config.BackupDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// 写入只包含 app.txt 的 tar.gz 升级包
+func writeTestPackage(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "app.txt", Mode: 0644, Size: int64(len(content))})
+	tw.Write([]byte(content))
+	tw.Close()
+	gz.Close()
+	return path
+}
+
+func upgradeTo(t *testing.T, e *Engine, version string) (*UpgradeResult, error) {
+	t.Helper()
+	name := "app-" + version + ".tar.gz"
+	return e.Upgrade(context.Background(), UpgradeRequest{FilePath: writeTestPackage(t, name, version), Filename: name}, nil)
+}
+
+func TestUpgradeStartFailure(t *testing.T) {
+	for _, rollback := range []bool{false, true} {
+		e := newTestEngine(t, func(c *Config) {
+			// 只有 1.0.0 能够启动
+			c.EnableService = true
+			c.ServiceManager = ServiceManagerCustom
+			c.ServiceCommands = &ServiceCommands{
+				Stop:   []string{"true"},
+				Start:  []string{"grep", "-q", "1.0.0", filepath.Join(c.TargetDir, "app.txt")},
+				Status: []string{"true"},
+			}
+			c.RollbackOnFailure = rollback
+		})
+		if _, err := upgradeTo(t, e, "1.0.0"); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := upgradeTo(t, e, "1.1.0")
+		if err == nil || result.Success {
+			t.Fatalf("rollback_on_failure=%v: 启动失败时升级应失败: %s", rollback, result.Message)
+		}
+		want := "1.1.0"
+		if rollback {
+			want = "1.0.0"
+		}
+		p := e.Profile("")
+		if deployed := p.Deployed(); deployed == nil || deployed.Version != want {
+			t.Errorf("rollback_on_failure=%v: 已部署 %+v, want %s", rollback, deployed, want)
+		}
+		if data, _ := os.ReadFile(filepath.Join(p.TargetDir, "app.txt")); string(data) != want {
+			t.Errorf("rollback_on_failure=%v: 目标目录内容 %q, want %s", rollback, data, want)
+		}
+	}
+}
+
+func TestConfirmVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		pending string
+		version string
+		ok      bool
+	}{
+		{"版本一致", "1.2.0", "1.2.0", true},
+		{"未提供版本", "1.2.0", "", true},
+		{"升级包没有版本号", "", "1.2.0", true},
+		{"版本不一致", "1.2.0", "1.1.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestEngine(t, nil).Profile("")
+			p.confirm.pending = &PendingUpgrade{Version: tt.pending}
+			err := p.confirmUpgrade(tt.version, "test")
+			if (err == nil) != tt.ok {
+				t.Errorf("confirmUpgrade(%q) = %v, want ok %v", tt.version, err, tt.ok)
+			}
+			if (p.confirm.pending == nil) != tt.ok {
+				t.Errorf("确认后待确认状态 = %+v", p.confirm.pending)
+			}
+		})
+	}
+	// 没有待确认的升级
+	if err := newTestEngine(t, nil).Profile("").confirmUpgrade("", "test"); err == nil {
+		t.Error("没有待确认的升级时应返回错误")
+	}
+}
+
+func TestUpgradeFilename(t *testing.T) {
+	e := newTestEngine(t, nil)
+	path := writeTestPackage(t, "app.tar.gz", "1.0.0")
+	for _, name := range []string{"", ".", "..", "../app.tar.gz", "sub/app.tar.gz", "/tmp/app.tar.gz"} {
+		result, err := e.Upgrade(context.Background(), UpgradeRequest{FilePath: path, Filename: name}, nil)
+		if !errors.Is(err, ErrInvalidFilename) || result.Success {
+			t.Errorf("%q: err = %v", name, err)
+		}
+	}
+	if entries, _ := os.ReadDir(e.Profile("").TargetDir); len(entries) != 0 {
+		t.Errorf("目标目录应为空，实际 %d 个文件", len(entries))
+	}
+}
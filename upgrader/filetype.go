@@ -0,0 +1,132 @@
+package upgrader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 升级包类型不被接受，或内容与扩展名不符
+var ErrFileType = errors.New("不支持的文件类型")
+
+// 根据文件头识别的内容类型
+type fileKind int
+
+const (
+	kindUnknown fileKind = iota
+	kindGzip
+	kindZip
+	kindELF
+	kindScript
+)
+
+func (k fileKind) String() string {
+	switch k {
+	case kindGzip:
+		return "gzip 压缩包"
+	case kindZip:
+		return "zip 压缩包"
+	case kindELF:
+		return "ELF 可执行文件"
+	case kindScript:
+		return "脚本"
+	default:
+		return "未知格式"
+	}
+}
+
+// 内容类型对应的 MIME 类型，用于匹配 accept_types 中的 MIME 条目
+func (k fileKind) mimeType() string {
+	switch k {
+	case kindGzip:
+		return "application/gzip"
+	case kindZip:
+		return "application/zip"
+	case kindELF:
+		return "application/x-executable"
+	case kindScript:
+		return "text/x-shellscript"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// 读取文件头识别内容类型
+func sniffFile(filePath string) (fileKind, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return kindUnknown, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 4)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return kindUnknown, err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return kindGzip, nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return kindZip, nil
+	case bytes.HasPrefix(header, []byte("\x7fELF")):
+		return kindELF, nil
+	case bytes.HasPrefix(header, []byte("#!")):
+		return kindScript, nil
+	}
+	return kindUnknown, nil
+}
+
+// 扩展名决定部署方式（见 deployProgram），对应的内容类型必须一致
+func expectedKind(filename string) fileKind {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return kindGzip
+	case strings.HasSuffix(name, ".zip"):
+		return kindZip
+	}
+	return kindUnknown
+}
+
+// 检查升级包是否符合配置档的 accept_types：
+// 以 . 开头的条目按扩展名匹配，其余按文件头识别出的 MIME 类型匹配，
+// application/octet-stream 匹配任意内容。扩展名与内容不符时总是拒绝
+func (p *Profile) checkFileType(filePath, filename string) error {
+	kind, err := sniffFile(filePath)
+	if err != nil {
+		return fmt.Errorf("读取升级包失败: %v", err)
+	}
+
+	// 压缩包扩展名必须是对应的压缩包，其他文件不能是压缩包，否则会按错误的方式部署
+	expected := expectedKind(filename)
+	switch {
+	case expected != kindUnknown && kind != expected:
+		return fmt.Errorf("%w: %s 的内容为 %s，不是 %s", ErrFileType, filename, kind, expected)
+	case expected == kindUnknown && (kind == kindGzip || kind == kindZip):
+		return fmt.Errorf("%w: %s 的内容为 %s，但扩展名不是压缩包", ErrFileType, filename, kind)
+	}
+
+	if len(p.AcceptTypes) == 0 {
+		return nil
+	}
+	name := strings.ToLower(filename)
+	for _, accept := range p.AcceptTypes {
+		accept = strings.ToLower(strings.TrimSpace(accept))
+		if strings.HasPrefix(accept, ".") {
+			if strings.HasSuffix(name, accept) {
+				return nil
+			}
+			continue
+		}
+		if accept == "application/octet-stream" || accept == kind.mimeType() {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s (%s)，允许的类型: %s", ErrFileType, filename, kind, strings.Join(p.AcceptTypes, ", "))
+}
@@ -0,0 +1,95 @@
+package upgrader
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testHeaders = map[fileKind][]byte{
+	kindGzip:    {0x1f, 0x8b, 0x08, 0x00},
+	kindZip:     []byte("PK\x03\x04rest"),
+	kindELF:     []byte("\x7fELF\x02\x01"),
+	kindScript:  []byte("#!/bin/sh\necho ok\n"),
+	kindUnknown: []byte("plain text"),
+}
+
+func writeTestFile(t *testing.T, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "package")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSniffFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    fileKind
+	}{
+		{"gzip", testHeaders[kindGzip], kindGzip},
+		{"zip", testHeaders[kindZip], kindZip},
+		{"空 zip", []byte("PK\x05\x06"), kindZip},
+		{"ELF", testHeaders[kindELF], kindELF},
+		{"脚本", testHeaders[kindScript], kindScript},
+		{"文本", testHeaders[kindUnknown], kindUnknown},
+		{"短文件", []byte{0x1f}, kindUnknown},
+		{"空文件", nil, kindUnknown},
+	}
+	for _, tt := range tests {
+		got, err := sniffFile(writeTestFile(t, tt.content))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: sniffFile = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := sniffFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("文件不存在时应当报错")
+	}
+}
+
+func TestCheckFileType(t *testing.T) {
+	defaults := DefaultConfig().AcceptTypes
+	tests := []struct {
+		name     string
+		accept   []string
+		filename string
+		kind     fileKind
+		ok       bool
+	}{
+		{"tar.gz", defaults, "app-1.0.0.tar.gz", kindGzip, true},
+		{"zip", defaults, "app.zip", kindZip, true},
+		{"ELF 程序", defaults, "app", kindELF, true},
+		{"脚本", defaults, "install.sh", kindScript, true},
+		{"默认不接受其他内容", defaults, "app.bin", kindUnknown, false},
+		{"实际为 ELF 的 zip", defaults, "app.zip", kindELF, false},
+		{"实际为 zip 的 tar.gz", defaults, "app.tar.gz", kindZip, false},
+		{"没有 .gz 扩展名的 gzip", defaults, "app", kindGzip, false},
+		{"大写扩展名", defaults, "APP.ZIP", kindZip, true},
+		{"显式接受任意内容", []string{"application/octet-stream"}, "app.bin", kindUnknown, true},
+		{"任意内容也不能掩盖扩展名不符", []string{"application/octet-stream"}, "app.zip", kindELF, false},
+		{"只接受扩展名", []string{".tar.gz"}, "app.zip", kindZip, false},
+		{"只接受 MIME 类型", []string{"application/x-executable"}, "app", kindELF, true},
+		{"只接受 MIME 类型，内容不符", []string{"application/x-executable"}, "app", kindScript, false},
+		{"未配置时不限制", nil, "app.bin", kindUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{}
+			p.AcceptTypes = tt.accept
+			err := p.checkFileType(writeTestFile(t, testHeaders[tt.kind]), tt.filename)
+			if (err == nil) != tt.ok {
+				t.Fatalf("checkFileType(%s, %s) = %v, want ok %v", tt.filename, tt.kind, err, tt.ok)
+			}
+			if err != nil && !errors.Is(err, ErrFileType) {
+				t.Errorf("错误应为 ErrFileType: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package upgrader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 健康检查类型
+const (
+	HealthCheckHTTP    = "http"
+	HealthCheckTCP     = "tcp"
+	HealthCheckCommand = "command"
+)
+
+// 健康检查定义，时间单位均为秒
+type HealthCheck struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // http / tcp / command
+
+	// http
+	URL          string `json:"url"`
+	ExpectStatus int    `json:"expect_status"` // 默认 200
+	BodyContains string `json:"body_contains"` // 响应体需包含的内容
+
+	// tcp
+	Address string `json:"address"`
+
+	// command：退出码为 0 视为成功
+	Command []string `json:"command"`
+
+	Timeout          int `json:"timeout"`           // 单次检查超时，默认 5
+	InitialDelay     int `json:"initial_delay"`     // 首次检查前等待
+	Interval         int `json:"interval"`          // 两次检查间隔，默认 2
+	Retries          int `json:"retries"`           // 失败后重试次数，默认 3
+	SuccessThreshold int `json:"success_threshold"` // 连续成功次数，默认 1
+	Deadline         int `json:"deadline"`          // 整体时限，默认 60
+}
+
+func (c HealthCheck) label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	switch c.Type {
+	case HealthCheckHTTP:
+		return "http " + c.URL
+	case HealthCheckTCP:
+		return "tcp " + c.Address
+	default:
+		return c.Type + " " + strings.Join(c.Command, " ")
+	}
+}
+
+func (c *HealthCheck) applyDefaults() {
+	if c.ExpectStatus == 0 {
+		c.ExpectStatus = http.StatusOK
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5
+	}
+	if c.Interval <= 0 {
+		c.Interval = 2
+	}
+	if c.Retries < 0 {
+		c.Retries = 0
+	} else if c.Retries == 0 {
+		c.Retries = 3
+	}
+	if c.SuccessThreshold <= 0 {
+		c.SuccessThreshold = 1
+	}
+	if c.Deadline <= 0 {
+		c.Deadline = 60
+	}
+}
+
+func (c HealthCheck) validate() error {
+	switch c.Type {
+	case HealthCheckHTTP:
+		if c.URL == "" {
+			return fmt.Errorf("http 健康检查缺少 url")
+		}
+	case HealthCheckTCP:
+		if c.Address == "" {
+			return fmt.Errorf("tcp 健康检查缺少 address")
+		}
+	case HealthCheckCommand:
+		if len(c.Command) == 0 {
+			return fmt.Errorf("command 健康检查缺少 command")
+		}
+	default:
+		return fmt.Errorf("未知的健康检查类型: %s", c.Type)
+	}
+	return nil
+}
+
+// 依次执行所有健康检查，任一检查失败即返回错误
+func runHealthChecks(ctx context.Context, checks []HealthCheck, logs *strings.Builder) error {
+	for _, check := range checks {
+		if err := runHealthCheck(ctx, check, logs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runHealthCheck(ctx context.Context, check HealthCheck, logs *strings.Builder) error {
+	check.applyDefaults()
+	label := check.label()
+	deadline := time.Now().Add(time.Duration(check.Deadline) * time.Second)
+
+	logs.WriteString(fmt.Sprintf("   健康检查 [%s]: 延迟 %ds, 间隔 %ds, 重试 %d 次, 时限 %ds\n",
+		label, check.InitialDelay, check.Interval, check.Retries, check.Deadline))
+	if err := sleepContext(ctx, time.Duration(check.InitialDelay)*time.Second); err != nil {
+		return err
+	}
+
+	failures, successes := 0, 0
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		err := probe(ctx, check, deadline)
+		elapsed := time.Since(started).Round(time.Millisecond)
+
+		if err == nil {
+			successes++
+			logs.WriteString(fmt.Sprintf("   [%s] 第 %d 次: 成功 (%v)\n", label, attempt, elapsed))
+			if successes >= check.SuccessThreshold {
+				logs.WriteString(fmt.Sprintf("   ✓ 健康检查通过: %s\n", label))
+				return nil
+			}
+		} else {
+			successes = 0
+			failures++
+			logs.WriteString(fmt.Sprintf("   [%s] 第 %d 次: 失败 (%v): %v\n", label, attempt, elapsed, err))
+			if failures > check.Retries {
+				return fmt.Errorf("健康检查 %s 失败: 已重试 %d 次", label, check.Retries)
+			}
+		}
+
+		next := time.Now().Add(time.Duration(check.Interval) * time.Second)
+		if next.After(deadline) {
+			return fmt.Errorf("健康检查 %s 失败: 超过时限 %ds", label, check.Deadline)
+		}
+		if err := sleepContext(ctx, time.Until(next)); err != nil {
+			return err
+		}
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("健康检查已取消: %v", ctx.Err())
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// 执行一次检查
+func probe(ctx context.Context, check HealthCheck, deadline time.Time) error {
+	timeout := time.Duration(check.Timeout) * time.Second
+	if remaining := time.Until(deadline); remaining < timeout {
+		timeout = remaining
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	switch check.Type {
+	case HealthCheckHTTP:
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != check.ExpectStatus {
+			return fmt.Errorf("状态码 %d, 期望 %d", resp.StatusCode, check.ExpectStatus)
+		}
+		if check.BodyContains != "" {
+			body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(string(body), check.BodyContains) {
+				return fmt.Errorf("响应内容不包含 %q", check.BodyContains)
+			}
+		}
+		return nil
+	case HealthCheckTCP:
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", check.Address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case HealthCheckCommand:
+		return runCommand(ctx, check.Command[0], check.Command[1:]...)
+	}
+	return fmt.Errorf("未知的健康检查类型: %s", check.Type)
+}
@@ -0,0 +1,78 @@
+package upgrader
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 能够提供服务输出的服务管理器
+type logProvider interface {
+	RecentLogs(ctx context.Context, service string, since time.Time, lines int) (string, error)
+}
+
+// systemd: 读取 journal
+func (systemdManager) RecentLogs(ctx context.Context, service string, since time.Time, lines int) (string, error) {
+	out, err := newCommand(ctx, "journalctl", "-u", service,
+		"--since", "@"+strconv.FormatInt(since.Unix(), 10),
+		"-n", strconv.Itoa(lines), "--no-pager", "-o", "short-iso").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("journalctl 执行失败: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// supervisord: supervisorctl tail 只能按字节读取，无法按时间过滤
+func (supervisorManager) RecentLogs(ctx context.Context, service string, since time.Time, lines int) (string, error) {
+	out, err := newCommand(ctx, "supervisorctl", "tail", "-"+strconv.Itoa(lines*200), service, "stderr").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("supervisorctl tail 执行失败: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return lastLines(string(out), lines), nil
+}
+
+// 内置守护：读取内存中的进程输出
+func (s *processSupervisor) RecentLogs(ctx context.Context, service string, since time.Time, lines int) (string, error) {
+	var b strings.Builder
+	for _, line := range s.output.tail(since, lines) {
+		b.WriteString(fmt.Sprintf("%s [%s] %s\n", line.Time.Format("2006-01-02 15:04:05.000"), line.Stream, line.Text))
+	}
+	return b.String(), nil
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// 启动或健康检查失败时收集服务输出，写入升级日志并返回
+func (p *Profile) captureServiceLogs(ctx context.Context, since time.Time, logs *strings.Builder) string {
+	provider, ok := p.svc.(logProvider)
+	if !ok || p.engine.config.FailureLogLines <= 0 {
+		return ""
+	}
+
+	ctx, cancel := withTimeout(ctx, p.Timeouts.Command)
+	defer cancel()
+	output, err := provider.RecentLogs(ctx, p.ServiceName, since, p.engine.config.FailureLogLines)
+	if err != nil {
+		logs.WriteString(fmt.Sprintf("   警告: 获取服务日志失败: %v\n", err))
+		return ""
+	}
+	if strings.TrimSpace(output) == "" {
+		logs.WriteString("   (启动后服务没有输出日志)\n")
+		return ""
+	}
+
+	logs.WriteString(fmt.Sprintf("   ---- 服务日志 (自 %s 起，最近 %d 行) ----\n", since.Format("15:04:05"), p.engine.config.FailureLogLines))
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		logs.WriteString("   | " + line + "\n")
+	}
+	logs.WriteString("   ----------------------------------------\n")
+	return output
+}
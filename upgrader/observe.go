@@ -0,0 +1,109 @@
+package upgrader
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 能够提供重启次数的服务管理器
+type restartCounter interface {
+	// RestartCount 返回累计重启次数以及服务是否处于运行状态
+	RestartCount(ctx context.Context, service string) (int, bool, error)
+}
+
+// systemd: systemctl show -p NRestarts -p ActiveState
+func (systemdManager) RestartCount(ctx context.Context, service string) (int, bool, error) {
+	out, err := newCommand(ctx, "systemctl", "show", service, "-p", "NRestarts", "-p", "ActiveState").Output()
+	if err != nil {
+		return 0, false, fmt.Errorf("systemctl show 执行失败: %v", err)
+	}
+
+	restarts, state := 0, ""
+	for _, line := range strings.Split(string(out), "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "NRestarts":
+			restarts, _ = strconv.Atoi(value)
+		case "ActiveState":
+			state = value
+		}
+	}
+	if state == "failed" {
+		return restarts, false, fmt.Errorf("服务处于 failed 状态")
+	}
+	return restarts, state == "active" || state == "activating" || state == "reloading", nil
+}
+
+// 内置守护：统计进程意外退出次数
+func (s *processSupervisor) RestartCount(ctx context.Context, service string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts, s.cmd != nil, nil
+}
+
+// 启动后立即读取重启计数，作为观察期的基准
+func (p *Profile) restartBaseline(ctx context.Context) int {
+	if counter, ok := p.svc.(restartCounter); ok {
+		ctx, cancel := withTimeout(ctx, p.Timeouts.Command)
+		defer cancel()
+		if n, _, err := counter.RestartCount(ctx, p.ServiceName); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// 启动成功后的观察期：重启次数超过阈值视为崩溃循环
+func (p *Profile) observeService(ctx context.Context, baseline int, logs *strings.Builder) error {
+	window := time.Duration(p.ObserveWindow) * time.Second
+	interval := 2 * time.Second
+	threshold := p.CrashLoopThreshold
+
+	logs.WriteString(fmt.Sprintf("   观察 %v，允许重启 %d 次\n", window, threshold))
+
+	counter, hasCounter := p.svc.(restartCounter)
+
+	// 不支持重启计数的服务管理器通过状态变化估算
+	crashes, wasActive := 0, true
+	deadline := time.Now().Add(window)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("观察已取消: %v", ctx.Err())
+		case <-time.After(min(interval, time.Until(deadline))):
+		}
+
+		if hasCounter {
+			countCtx, cancel := withTimeout(ctx, p.Timeouts.Command)
+			n, active, err := counter.RestartCount(countCtx, p.ServiceName)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("观察期内服务异常: %v", err)
+			}
+			crashes = n - baseline
+			wasActive = active
+		} else {
+			active := p.serviceStatus(ctx) == nil
+			if wasActive && !active {
+				crashes++
+			}
+			wasActive = active
+		}
+
+		if crashes > threshold {
+			logs.WriteString(fmt.Sprintf("   ✗ 观察期内重启 %d 次，超过阈值 %d\n", crashes, threshold))
+			return fmt.Errorf("检测到崩溃循环: 观察期内重启 %d 次", crashes)
+		}
+	}
+
+	if !wasActive {
+		logs.WriteString("   ✗ 观察期结束时服务未运行\n")
+		return fmt.Errorf("观察期结束时服务未运行")
+	}
+
+	logs.WriteString(fmt.Sprintf("   ✓ 观察期内服务运行稳定 (重启 %d 次)\n", crashes))
+	return nil
+}
@@ -0,0 +1,538 @@
+package upgrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 内置升级步骤
+const (
+	StepStop        = "stop"
+	StepBackup      = "backup"
+	StepDeploy      = "deploy"
+	StepPermissions = "permissions"
+	StepRecord      = "record"
+	StepStart       = "start"
+	StepVerify      = "verify"
+	StepConfirm     = "confirm"
+)
+
+// 默认升级流程
+var defaultSteps = []string{StepStop, StepBackup, StepDeploy, StepPermissions, StepRecord, StepStart, StepVerify, StepConfirm}
+
+// 步骤状态
+const (
+	StepStatusSuccess = "success"
+	StepStatusWarning = "warning"
+	StepStatusFailed  = "failed"
+	StepStatusSkipped = "skipped"
+)
+
+// 升级步骤
+type Step interface {
+	Name() string
+	// Describe 返回步骤说明，第一行用作升级日志和页面流程中的标题，其余行为细节
+	Describe(p *Profile) []string
+	// Enabled 返回当前配置档是否执行该步骤
+	Enabled(p *Profile) bool
+	// Run 返回 warning(err) 时记录警告并继续，返回其他错误时终止升级
+	Run(ctx context.Context, u *upgradeRun) error
+}
+
+// 自定义步骤：执行一条命令
+// 命令参数支持 {profile} {service} {target_dir} {backup_dir} {file} {version} 占位符
+type CustomStep struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Command     []string `json:"command"`
+	Timeout     int      `json:"timeout"`      // 秒，默认 timeouts.command
+	IgnoreError bool     `json:"ignore_error"` // 失败时只记录警告
+}
+
+// 单个步骤的执行结果
+type StepResult struct {
+	Name     string  `json:"name"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration"` // 秒
+	Message  string  `json:"message,omitempty"`
+}
+
+// 一次升级过程中各步骤共享的状态
+type upgradeRun struct {
+	profile *Profile
+	req     UpgradeRequest
+	logs    *strings.Builder
+	result  *UpgradeResult
+	onEvent EventFunc
+
+	previous        *DeployRecord // 升级前的部署记录
+	backupPath      string
+	startedAt       time.Time
+	restartBaseline int
+}
+
+// 非致命错误
+type stepWarning struct {
+	err error
+}
+
+func (w stepWarning) Error() string {
+	return w.err.Error()
+}
+
+func warning(err error) error {
+	return stepWarning{err: err}
+}
+
+// 根据步骤名称列表构建配置档的升级流程
+func buildPipeline(p *Profile) ([]Step, error) {
+	custom := make(map[string]CustomStep)
+	for _, c := range p.CustomSteps {
+		if c.Name == "" || len(c.Command) == 0 {
+			return nil, fmt.Errorf("自定义步骤需要 name 和 command")
+		}
+		if _, ok := builtinStep(c.Name); ok {
+			return nil, fmt.Errorf("自定义步骤 %s 与内置步骤重名", c.Name)
+		}
+		custom[c.Name] = c
+	}
+
+	seen := make(map[string]bool)
+	var steps []Step
+	for _, name := range p.Steps {
+		if seen[name] {
+			return nil, fmt.Errorf("步骤 %s 重复", name)
+		}
+		seen[name] = true
+
+		if step, ok := builtinStep(name); ok {
+			steps = append(steps, step)
+		} else if c, ok := custom[name]; ok {
+			steps = append(steps, customStep{c})
+		} else {
+			return nil, fmt.Errorf("未知的步骤: %s", name)
+		}
+	}
+	if !seen[StepDeploy] {
+		return nil, fmt.Errorf("步骤列表必须包含 %s", StepDeploy)
+	}
+	return steps, nil
+}
+
+func builtinStep(name string) (Step, bool) {
+	switch name {
+	case StepStop:
+		return stopStep{}, true
+	case StepBackup:
+		return backupStep{}, true
+	case StepDeploy:
+		return deployStep{}, true
+	case StepPermissions:
+		return permissionsStep{}, true
+	case StepRecord:
+		return recordStep{}, true
+	case StepStart:
+		return startStep{}, true
+	case StepVerify:
+		return verifyStep{}, true
+	case StepConfirm:
+		return confirmStep{}, true
+	}
+	return nil, false
+}
+
+// 页面上升级流程说明的一项
+type FlowItem struct {
+	Title   string
+	Details []string
+}
+
+// 页面上的升级流程说明，只包含当前配置档会执行的步骤
+func (p *Profile) Flow() []FlowItem {
+	var items []FlowItem
+	for _, step := range p.pipeline {
+		if !step.Enabled(p) {
+			continue
+		}
+		desc := step.Describe(p)
+		items = append(items, FlowItem{
+			Title:   fmt.Sprintf("%d. %s", len(items)+1, desc[0]),
+			Details: desc[1:],
+		})
+	}
+	return items
+}
+
+// 依次执行升级流程，返回各步骤的结果
+func (p *Profile) runPipeline(ctx context.Context, u *upgradeRun) ([]StepResult, error) {
+	var results []StepResult
+	number := 1
+	for _, step := range p.pipeline {
+		result := StepResult{Name: step.Name(), Status: StepStatusSkipped}
+		if !step.Enabled(p) {
+			results = append(results, result)
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return results, fmt.Errorf("升级已取消: %v", err)
+		}
+
+		u.logs.WriteString(fmt.Sprintf("\n%d. %s...\n", number, step.Describe(p)[0]))
+		number++
+		u.onEvent.emit(p.Name, Event{Type: EventStepStart, Step: step.Name(), Message: step.Describe(p)[0]})
+
+		started := time.Now()
+		offset := u.logs.Len()
+		err := step.Run(ctx, u)
+		result.Duration = time.Since(started).Seconds()
+		result.Status = StepStatusSuccess
+
+		var warn stepWarning
+		switch {
+		case err == nil:
+		case errors.As(err, &warn):
+			result.Status = StepStatusWarning
+			result.Message = warn.Error()
+		default:
+			result.Status = StepStatusFailed
+			result.Message = err.Error()
+		}
+		u.onEvent.emit(p.Name, Event{
+			Type:     EventStepEnd,
+			Step:     result.Name,
+			Status:   result.Status,
+			Message:  result.Message,
+			Logs:     u.logs.String()[offset:],
+			Duration: result.Duration,
+		})
+		results = append(results, result)
+		if result.Status == StepStatusFailed {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// 升级日志末尾的步骤汇总
+func writeStepSummary(logs *strings.Builder, results []StepResult) {
+	logs.WriteString("\n步骤汇总:\n")
+	for _, r := range results {
+		if r.Status == StepStatusSkipped {
+			continue
+		}
+		logs.WriteString(fmt.Sprintf("   %-12s %-8s %6.2fs\n", r.Name, r.Status, r.Duration))
+	}
+}
+
+// 停止服务
+type stopStep struct{}
+
+func (stopStep) Name() string {
+	return StepStop
+}
+
+func (stopStep) Describe(p *Profile) []string {
+	return []string{fmt.Sprintf("停止当前服务 (%s)", p.ServiceName)}
+}
+
+func (stopStep) Enabled(p *Profile) bool {
+	return p.ServiceEnabled()
+}
+
+func (stopStep) Run(ctx context.Context, u *upgradeRun) error {
+	if err := u.profile.stopService(ctx, u.logs); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("升级已取消: %v", ctx.Err())
+		}
+		u.logs.WriteString(fmt.Sprintf("   警告: 停止服务失败 (可能服务不存在): %v\n", err))
+		return warning(err)
+	}
+	u.logs.WriteString("   ✓ 服务已停止\n")
+	return nil
+}
+
+// 备份现有程序
+type backupStep struct{}
+
+func (backupStep) Name() string {
+	return StepBackup
+}
+
+func (backupStep) Describe(p *Profile) []string {
+	return []string{fmt.Sprintf("备份现有程序到 %s", p.BackupDir)}
+}
+
+func (backupStep) Enabled(p *Profile) bool {
+	return p.BackupEnabled()
+}
+
+func (backupStep) Run(ctx context.Context, u *upgradeRun) error {
+	p := u.profile
+	if err := os.MkdirAll(p.BackupDir, ParsePermission(p.DirPermission)); err != nil {
+		return fmt.Errorf("创建目录 %s 失败: %v", p.BackupDir, err)
+	}
+	if _, err := os.Stat(p.TargetDir); os.IsNotExist(err) {
+		u.logs.WriteString("   目标目录不存在，跳过备份\n")
+		return nil
+	}
+
+	path, err := p.createBackup(ctx, u.logs)
+	if err != nil {
+		u.logs.WriteString(fmt.Sprintf("   警告: 备份失败 (可能没有现有程序): %v\n", err))
+		return warning(err)
+	}
+	u.backupPath = path
+	u.logs.WriteString(fmt.Sprintf("   ✓ 备份已保存到: %s\n", path))
+	return nil
+}
+
+// 部署新程序
+type deployStep struct{}
+
+func (deployStep) Name() string {
+	return StepDeploy
+}
+
+func (deployStep) Describe(p *Profile) []string {
+	return []string{fmt.Sprintf("部署新程序到 %s", p.TargetDir)}
+}
+
+func (deployStep) Enabled(p *Profile) bool {
+	return true
+}
+
+func (deployStep) Run(ctx context.Context, u *upgradeRun) error {
+	p := u.profile
+	if err := os.MkdirAll(p.TargetDir, ParsePermission(p.DirPermission)); err != nil {
+		return fmt.Errorf("创建目录 %s 失败: %v", p.TargetDir, err)
+	}
+	return deployProgram(ctx, p, u.req.FilePath, u.req.Filename, u.logs)
+}
+
+// 设置权限
+type permissionsStep struct{}
+
+func (permissionsStep) Name() string {
+	return StepPermissions
+}
+
+func (permissionsStep) Describe(p *Profile) []string {
+	return []string{fmt.Sprintf("设置权限 (目录:%s, 文件:%s, 可执行:%s)", p.DirPermission, p.FilePermission, p.ExecPermission)}
+}
+
+func (permissionsStep) Enabled(p *Profile) bool {
+	return true
+}
+
+func (permissionsStep) Run(ctx context.Context, u *upgradeRun) error {
+	return setPermissions(u.profile, u.logs)
+}
+
+// 记录版本信息
+type recordStep struct{}
+
+func (recordStep) Name() string {
+	return StepRecord
+}
+
+func (recordStep) Describe(p *Profile) []string {
+	return []string{"记录版本信息"}
+}
+
+func (recordStep) Enabled(p *Profile) bool {
+	return true
+}
+
+func (recordStep) Run(ctx context.Context, u *upgradeRun) error {
+	u.profile.recordDeployment(ctx, u.req.FilePath, u.req.Filename, u.req.SHA256, u.logs)
+	return nil
+}
+
+// 启动服务，未配置健康检查时简单检查一次运行状态
+type startStep struct{}
+
+func (startStep) Name() string {
+	return StepStart
+}
+
+func (startStep) Describe(p *Profile) []string {
+	return []string{fmt.Sprintf("启动服务 (%s)", p.ServiceName)}
+}
+
+func (startStep) Enabled(p *Profile) bool {
+	return p.ServiceEnabled()
+}
+
+func (startStep) Run(ctx context.Context, u *upgradeRun) error {
+	p := u.profile
+	u.startedAt = time.Now()
+	if err := p.startService(ctx); err != nil {
+		u.logs.WriteString(fmt.Sprintf("   ✗ 启动服务失败: %v\n", err))
+		u.result.ServiceLogs = p.captureServiceLogs(ctx, u.startedAt, u.logs)
+		err = fmt.Errorf("启动服务失败: %v", err)
+		if p.RollbackEnabled() {
+			err = p.rollbackAfterFailure(ctx, u.backupPath, u.previous, err, u.req.Remote, u.logs)
+		}
+		return err
+	}
+	u.logs.WriteString("   ✓ 服务已启动\n")
+	u.restartBaseline = p.restartBaseline(ctx)
+
+	// 未配置健康检查时检查一次服务状态
+	if len(p.HealthChecks) > 0 {
+		return nil
+	}
+	if err := p.serviceStatus(ctx); err != nil {
+		u.logs.WriteString("   警告：服务状态检查失败，请手动验证\n")
+		u.result.ServiceLogs = p.captureServiceLogs(ctx, u.startedAt, u.logs)
+		return warning(err)
+	}
+	u.logs.WriteString("   ✓ 服务运行正常\n")
+	return nil
+}
+
+// 健康检查与观察期，失败时按配置回滚
+type verifyStep struct{}
+
+func (verifyStep) Name() string {
+	return StepVerify
+}
+
+func (verifyStep) Describe(p *Profile) []string {
+	lines := []string{"验证新版本"}
+	for _, c := range p.HealthChecks {
+		lines = append(lines, "健康检查: "+c.label())
+	}
+	if p.ServiceEnabled() && p.ObserveWindow > 0 {
+		lines = append(lines, fmt.Sprintf("观察 %d 秒，重启超过 %d 次视为失败", p.ObserveWindow, p.CrashLoopThreshold))
+	}
+	if p.RollbackEnabled() {
+		lines = append(lines, "验证失败时自动回滚")
+	}
+	return lines
+}
+
+func (verifyStep) Enabled(p *Profile) bool {
+	return len(p.HealthChecks) > 0 || (p.ServiceEnabled() && p.ObserveWindow > 0)
+}
+
+func (verifyStep) Run(ctx context.Context, u *upgradeRun) error {
+	p := u.profile
+	err := runHealthChecks(ctx, p.HealthChecks, u.logs)
+	if err == nil && p.ServiceEnabled() && p.ObserveWindow > 0 {
+		u.logs.WriteString("   观察运行状态...\n")
+		err = p.observeService(ctx, u.restartBaseline, u.logs)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if p.ServiceEnabled() {
+		u.result.ServiceLogs = p.captureServiceLogs(ctx, u.startedAt, u.logs)
+	}
+	if p.RollbackEnabled() {
+		err = p.rollbackAfterFailure(ctx, u.backupPath, u.previous, err, u.req.Remote, u.logs)
+	}
+	return err
+}
+
+// 进入待确认状态
+type confirmStep struct{}
+
+func (confirmStep) Name() string {
+	return StepConfirm
+}
+
+func (confirmStep) Describe(p *Profile) []string {
+	return []string{fmt.Sprintf("等待应用在 %d 秒内确认，超时自动恢复升级前的版本", p.ConfirmTimeout)}
+}
+
+func (confirmStep) Enabled(p *Profile) bool {
+	return p.ConfirmTimeout > 0
+}
+
+func (confirmStep) Run(ctx context.Context, u *upgradeRun) error {
+	p := u.profile
+	pending := &PendingUpgrade{
+		Filename:   u.req.Filename,
+		BackupPath: u.backupPath,
+		Previous:   u.previous,
+		DeployedAt: time.Now(),
+		Deadline:   time.Now().Add(time.Duration(p.ConfirmTimeout) * time.Second),
+	}
+	if record := p.Deployed(); record != nil {
+		pending.Version = record.Version
+	}
+	if err := p.setPending(pending); err != nil {
+		u.logs.WriteString(fmt.Sprintf("   警告: %v\n", err))
+		return warning(err)
+	}
+
+	u.logs.WriteString(fmt.Sprintf("   截止 %s，超时未确认将自动恢复\n", pending.Deadline.Format("2006-01-02 15:04:05")))
+	if u.backupPath == "" {
+		u.logs.WriteString("   警告: 本次升级没有备份，超时后无法自动恢复\n")
+		return warning(fmt.Errorf("没有备份，超时后无法自动恢复"))
+	}
+	return nil
+}
+
+// 自定义命令步骤
+type customStep struct {
+	CustomStep
+}
+
+func (s customStep) Name() string {
+	return s.CustomStep.Name
+}
+
+func (s customStep) Describe(p *Profile) []string {
+	if s.Description != "" {
+		return []string{s.Description}
+	}
+	return []string{"执行 " + strings.Join(s.Command, " ")}
+}
+
+func (customStep) Enabled(p *Profile) bool {
+	return true
+}
+
+func (s customStep) Run(ctx context.Context, u *upgradeRun) error {
+	p := u.profile
+	version := ""
+	if record := p.Deployed(); record != nil {
+		version = record.Version
+	}
+	replacer := strings.NewReplacer(
+		"{profile}", p.Name,
+		"{service}", p.ServiceName,
+		"{target_dir}", p.TargetDir,
+		"{backup_dir}", p.BackupDir,
+		"{file}", u.req.FilePath,
+		"{version}", version,
+	)
+	args := make([]string, len(s.Command))
+	for i, arg := range s.Command {
+		args[i] = replacer.Replace(arg)
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = p.Timeouts.Command
+	}
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := runCommand(ctx, args[0], args[1:]...); err != nil {
+		if s.IgnoreError {
+			u.logs.WriteString(fmt.Sprintf("   警告: %v\n", err))
+			return warning(err)
+		}
+		return fmt.Errorf("步骤 %s 失败: %v", s.CustomStep.Name, err)
+	}
+	u.logs.WriteString("   ✓ 执行完成\n")
+	return nil
+}
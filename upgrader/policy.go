@@ -0,0 +1,169 @@
+package upgrader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 版本策略
+const (
+	PolicyAllow = "allow"
+	PolicyWarn  = "warn"
+	PolicyDeny  = "deny"
+)
+
+// 语义化版本
+type semver struct {
+	major, minor, patch int
+	prerelease          []string
+}
+
+func parseSemver(v string) (semver, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "+") // 构建元数据不参与比较
+
+	core, pre, hasPre := strings.Cut(v, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+
+	s := semver{major: nums[0], minor: nums[1], patch: nums[2]}
+	if hasPre {
+		s.prerelease = strings.Split(pre, ".")
+	}
+	return s, true
+}
+
+// 按 semver 2.0 规则比较版本，返回 -1/0/1
+func compareSemver(a, b semver) int {
+	for _, d := range [][2]int{{a.major, b.major}, {a.minor, b.minor}, {a.patch, b.patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	// 有预发布标识的版本低于正式版本
+	switch {
+	case len(a.prerelease) == 0 && len(b.prerelease) == 0:
+		return 0
+	case len(a.prerelease) == 0:
+		return 1
+	case len(b.prerelease) == 0:
+		return -1
+	}
+
+	for i := 0; i < len(a.prerelease) && i < len(b.prerelease); i++ {
+		x, y := a.prerelease[i], b.prerelease[i]
+		if x == y {
+			continue
+		}
+		xn, xErr := strconv.Atoi(x)
+		yn, yErr := strconv.Atoi(y)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xn < yn {
+				return -1
+			}
+			return 1
+		case xErr == nil:
+			return -1
+		case yErr == nil:
+			return 1
+		case x < y:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	switch {
+	case len(a.prerelease) < len(b.prerelease):
+		return -1
+	case len(a.prerelease) > len(b.prerelease):
+		return 1
+	}
+	return 0
+}
+
+// 按 semver 比较两个版本号，返回 -1/0/1；任一版本号无法解析时 ok 为 false
+func CompareVersions(a, b string) (result int, ok bool) {
+	va, okA := parseSemver(a)
+	vb, okB := parseSemver(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	return compareSemver(va, vb), true
+}
+
+// 版本策略检查结果
+type policyDecision struct {
+	FromVersion string
+	ToVersion   string
+	Kind        string // upgrade / downgrade / same / unknown
+	Policy      string
+	Forced      bool
+}
+
+// 检查降级与重复安装策略，deny 且未强制时返回错误
+func (p *Profile) checkVersionPolicy(filePath, filename string, force bool, logs *strings.Builder) (*policyDecision, error) {
+	decision := &policyDecision{Kind: "unknown", Policy: PolicyAllow}
+	if record := p.Deployed(); record != nil {
+		decision.FromVersion = record.Version
+	}
+	decision.ToVersion, _ = p.detectPackageVersion(filePath, filename)
+
+	logs.WriteString(fmt.Sprintf("版本: %s -> %s\n", displayVersion(decision.FromVersion), displayVersion(decision.ToVersion)))
+
+	from, okFrom := parseSemver(decision.FromVersion)
+	to, okTo := parseSemver(decision.ToVersion)
+	if !okFrom || !okTo {
+		logs.WriteString("   版本号无法按 semver 比较，跳过版本策略检查\n")
+		return decision, nil
+	}
+
+	switch compareSemver(to, from) {
+	case 1:
+		decision.Kind = "upgrade"
+		return decision, nil
+	case 0:
+		decision.Kind = "same"
+		decision.Policy = p.SameVersionPolicy
+	default:
+		decision.Kind = "downgrade"
+		decision.Policy = p.DowngradePolicy
+	}
+
+	desc := "降级"
+	if decision.Kind == "same" {
+		desc = "重复安装相同版本"
+	}
+
+	switch decision.Policy {
+	case PolicyAllow:
+		return decision, nil
+	case PolicyDeny:
+		if !force {
+			return decision, fmt.Errorf("%w%s (%s -> %s)，如确需执行请勾选强制升级", ErrPolicy, desc, decision.FromVersion, decision.ToVersion)
+		}
+		decision.Forced = true
+		logs.WriteString(fmt.Sprintf("   警告: 策略禁止%s，已强制执行\n", desc))
+	default:
+		decision.Forced = force
+		logs.WriteString(fmt.Sprintf("   警告: 本次升级为%s\n", desc))
+	}
+	return decision, nil
+}
@@ -0,0 +1,136 @@
+package upgrader
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		in   string
+		want semver
+		ok   bool
+	}{
+		{"1.2.3", semver{major: 1, minor: 2, patch: 3}, true},
+		{"v1.2.3", semver{major: 1, minor: 2, patch: 3}, true},
+		{" 1.2.3 ", semver{major: 1, minor: 2, patch: 3}, true},
+		{"1.2.3+build.5", semver{major: 1, minor: 2, patch: 3}, true},
+		{"1.2.3-rc.1", semver{major: 1, minor: 2, patch: 3, prerelease: []string{"rc", "1"}}, true},
+		{"1.2.3-rc.1+build", semver{major: 1, minor: 2, patch: 3, prerelease: []string{"rc", "1"}}, true},
+		{"1.2", semver{}, false},
+		{"1.2.3.4", semver{}, false},
+		{"1.x.3", semver{}, false},
+		{"1.-2.3", semver{}, false},
+		{"", semver{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSemver(tt.in)
+		if ok != tt.ok {
+			t.Errorf("parseSemver(%q) ok = %v, want %v", tt.in, ok, tt.ok)
+			continue
+		}
+		if ok && (got.major != tt.want.major || got.minor != tt.want.minor || got.patch != tt.want.patch ||
+			strings.Join(got.prerelease, ".") != strings.Join(tt.want.prerelease, ".")) {
+			t.Errorf("parseSemver(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCompareVersionsOrdering(t *testing.T) {
+	// semver 2.0 规范第 11 条给出的优先级顺序
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"1.10.0",
+		"2.0.0",
+	}
+	for i, a := range ordered {
+		for j, b := range ordered {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			x, _ := parseSemver(a)
+			y, _ := parseSemver(b)
+			if got := compareSemver(x, y); got != want {
+				t.Errorf("compareSemver(%q, %q) = %d, want %d", a, b, got, want)
+			}
+		}
+	}
+}
+
+func TestCompareSemver(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3+a", "1.2.3+b", 0},
+		{"1.2.3-1", "1.2.3-a", -1},
+		{"1.2.3-10", "1.2.3-9", 1},
+	}
+	for _, tt := range tests {
+		x, _ := parseSemver(tt.a)
+		y, _ := parseSemver(tt.b)
+		if got := compareSemver(x, y); got != tt.want {
+			t.Errorf("compareSemver(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckVersionPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		deployed   string
+		filename   string
+		downgrade  string
+		same       string
+		force      bool
+		wantKind   string
+		wantPolicy string
+		wantForced bool
+		wantErr    bool
+	}{
+		{"升级", "1.0.0", "app-1.1.0.tar.gz", PolicyDeny, PolicyDeny, false, "upgrade", PolicyAllow, false, false},
+		{"未部署", "", "app-1.0.0.tar.gz", PolicyDeny, PolicyDeny, false, "unknown", PolicyAllow, false, false},
+		{"安装包无版本", "1.0.0", "app.tar.gz", PolicyDeny, PolicyDeny, false, "unknown", PolicyAllow, false, false},
+		{"降级被禁止", "1.1.0", "app-1.0.0.tar.gz", PolicyDeny, PolicyAllow, false, "downgrade", PolicyDeny, false, true},
+		{"强制降级", "1.1.0", "app-1.0.0.tar.gz", PolicyDeny, PolicyAllow, true, "downgrade", PolicyDeny, true, false},
+		{"降级警告", "1.1.0", "app-1.0.0.tar.gz", PolicyWarn, PolicyDeny, false, "downgrade", PolicyWarn, false, false},
+		{"允许降级", "1.1.0", "app-1.0.0.tar.gz", PolicyAllow, PolicyDeny, false, "downgrade", PolicyAllow, false, false},
+		{"正式版降为预发布", "1.0.0", "app-1.0.0-rc.1.tar.gz", PolicyDeny, PolicyAllow, false, "downgrade", PolicyDeny, false, true},
+		{"重复安装被禁止", "1.0.0", "app-v1.0.0.tar.gz", PolicyAllow, PolicyDeny, false, "same", PolicyDeny, false, true},
+		{"重复安装警告", "1.0.0", "app-1.0.0.tar.gz", PolicyDeny, PolicyWarn, false, "same", PolicyWarn, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Profile{}
+			p.DowngradePolicy = tt.downgrade
+			p.SameVersionPolicy = tt.same
+			if tt.deployed != "" {
+				p.deploy = &DeployRecord{Version: tt.deployed}
+			}
+
+			var logs strings.Builder
+			decision, err := p.checkVersionPolicy("", tt.filename, tt.force, &logs)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrPolicy)) {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if decision.Kind != tt.wantKind || decision.Policy != tt.wantPolicy || decision.Forced != tt.wantForced {
+				t.Errorf("decision = %+v, want kind %s policy %s forced %v", decision, tt.wantKind, tt.wantPolicy, tt.wantForced)
+			}
+		})
+	}
+}
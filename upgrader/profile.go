@@ -0,0 +1,337 @@
+package upgrader
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// 默认配置档名称（未配置 profiles 时使用顶层配置）
+const defaultProfileName = "default"
+
+// 配置档配置：一个上位机程序对应一个配置档，未填写的字段继承顶层配置
+type ProfileConfig struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+
+	// 目录与服务
+	TargetDir     string `json:"target_dir"`
+	BackupDir     string `json:"backup_dir"`
+	ServiceName   string `json:"service_name"`
+	EnableBackup  *bool  `json:"enable_backup,omitempty"`
+	EnableService *bool  `json:"enable_service,omitempty"`
+
+	ServiceManager  string           `json:"service_manager"`
+	ServiceCommands *ServiceCommands `json:"service_commands,omitempty"`
+	Process         *ProcessConfig   `json:"process,omitempty"`
+	HealthChecks    []HealthCheck    `json:"health_checks,omitempty"`
+
+	// 升级后观察期
+	ObserveWindow      int    `json:"observe_window"`
+	CrashLoopThreshold int    `json:"crash_loop_threshold"`
+	RollbackOnFailure  *bool  `json:"rollback_on_failure,omitempty"`
+	ConfirmTimeout     int    `json:"confirm_timeout"`
+	ConfirmToken       string `json:"confirm_token"`
+
+	// 外部命令超时，未配置的项继承全局配置
+	Timeouts *CommandTimeouts `json:"timeouts,omitempty"`
+
+	// 升级流程，未配置时继承全局配置
+	Steps       []string     `json:"steps,omitempty"`
+	CustomSteps []CustomStep `json:"custom_steps,omitempty"`
+
+	// 权限
+	DirPermission  string `json:"dir_permission"`
+	FilePermission string `json:"file_permission"`
+	ExecPermission string `json:"exec_permission"`
+
+	AcceptTypes []string `json:"accept_types"`
+
+	// 版本
+	ManifestFile      string   `json:"manifest_file"`
+	VersionCommand    []string `json:"version_command"`
+	DowngradePolicy   string   `json:"downgrade_policy"`
+	SameVersionPolicy string   `json:"same_version_policy"`
+
+	// 发布渠道
+	Channel  string   `json:"channel"`
+	Channels []string `json:"channels,omitempty"`
+}
+
+// 运行中的配置档：继承字段已填充的配置及其运行时状态
+type Profile struct {
+	ProfileConfig
+
+	engine   *Engine
+	svc      ServiceManager
+	lock     sync.Mutex   // 同一配置档同一时间只允许一个升级
+	deployMu sync.RWMutex // 保护 deploy 和 channel
+	deploy   *DeployRecord
+	channel  string // 切换过的发布渠道，为空时使用配置
+	histMu   sync.Mutex
+	confirm  confirmState
+	pipeline []Step
+}
+
+// 升级历史记录
+type HistoryEntry struct {
+	Time        time.Time `json:"time"`
+	Filename    string    `json:"filename"`
+	FromVersion string    `json:"from_version,omitempty"`
+	ToVersion   string    `json:"to_version,omitempty"`
+	Force       bool      `json:"force"`
+	Result      string    `json:"result"`
+	Message     string    `json:"message,omitempty"`
+	Duration    float64   `json:"duration"` // 秒
+}
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+func (p *Profile) BackupEnabled() bool {
+	return p.EnableBackup != nil && *p.EnableBackup
+}
+
+func (p *Profile) ServiceEnabled() bool {
+	return p.EnableService != nil && *p.EnableService
+}
+
+func (p *Profile) RollbackEnabled() bool {
+	return p.RollbackOnFailure != nil && *p.RollbackOnFailure
+}
+
+// 是否正在升级或执行服务操作
+func (p *Profile) Upgrading() bool {
+	if !p.lock.TryLock() {
+		return true
+	}
+	p.lock.Unlock()
+	return false
+}
+
+// 服务管理后端名称
+func (p *Profile) ManagerName() string {
+	return p.svc.Name()
+}
+
+// 配置档状态目录，保存部署记录与历史
+func (p *Profile) stateDir() string {
+	return filepath.Join(p.engine.config.StateDir, p.Name)
+}
+
+// 初始化配置档：未配置时由顶层配置生成默认配置档，并填充继承字段
+// 配置档由引擎持有各自的副本，不会修改调用方传入的配置
+func (e *Engine) initProfiles() ([]*Profile, error) {
+	config := &e.config
+	configs := config.Profiles
+	if len(configs) == 0 {
+		configs = []ProfileConfig{{
+			Name:        defaultProfileName,
+			TargetDir:   config.TargetDir,
+			BackupDir:   config.BackupDir,
+			ServiceName: config.ServiceName,
+		}}
+	}
+
+	list := make([]*Profile, 0, len(configs))
+	seen := make(map[string]bool)
+	for _, pc := range configs {
+		p := &Profile{ProfileConfig: pc, engine: e}
+		if pc.Timeouts != nil {
+			timeouts := *pc.Timeouts
+			p.Timeouts = &timeouts
+		}
+		list = append(list, p)
+
+		if !profileNamePattern.MatchString(p.Name) {
+			return nil, fmt.Errorf("配置档名称无效: %q", p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("配置档名称重复: %s", p.Name)
+		}
+		seen[p.Name] = true
+
+		if p.TargetDir == "" {
+			return nil, fmt.Errorf("配置档 %s 未设置 target_dir", p.Name)
+		}
+		if p.BackupDir == "" {
+			p.BackupDir = filepath.Join(config.BackupDir, p.Name)
+		}
+		if p.ServiceName == "" {
+			p.ServiceName = p.Name
+		}
+		if p.Title == "" {
+			p.Title = p.Name
+		}
+		if p.Description == "" {
+			p.Description = config.Description
+		}
+		if p.EnableBackup == nil {
+			enabled := config.EnableBackup
+			p.EnableBackup = &enabled
+		}
+		if p.EnableService == nil {
+			enabled := config.EnableService
+			p.EnableService = &enabled
+		}
+		if p.ServiceManager == "" {
+			p.ServiceManager = config.ServiceManager
+		}
+		if p.ServiceCommands == nil {
+			p.ServiceCommands = config.ServiceCommands
+		}
+		if p.Process == nil {
+			p.Process = config.Process
+		}
+		svc, err := newServiceManager(p)
+		if err != nil {
+			return nil, fmt.Errorf("配置档 %s: %v", p.Name, err)
+		}
+		p.svc = svc
+
+		if p.HealthChecks == nil {
+			p.HealthChecks = config.HealthChecks
+		}
+		for _, check := range p.HealthChecks {
+			if err := check.validate(); err != nil {
+				return nil, fmt.Errorf("配置档 %s: %v", p.Name, err)
+			}
+		}
+
+		if p.ObserveWindow == 0 {
+			p.ObserveWindow = config.ObserveWindow
+		}
+		if p.CrashLoopThreshold == 0 {
+			p.CrashLoopThreshold = config.CrashLoopThreshold
+		}
+		if p.Timeouts == nil {
+			p.Timeouts = &CommandTimeouts{}
+		}
+		p.Timeouts.applyDefaults(config.Timeouts)
+		if p.ConfirmTimeout == 0 {
+			p.ConfirmTimeout = config.ConfirmTimeout
+		}
+		if p.ConfirmToken == "" {
+			p.ConfirmToken = config.ConfirmToken
+		}
+		if p.RollbackOnFailure == nil {
+			enabled := config.RollbackOnFailure
+			p.RollbackOnFailure = &enabled
+		}
+
+		if len(p.Steps) == 0 {
+			p.Steps = config.Steps
+		}
+		if p.CustomSteps == nil {
+			p.CustomSteps = config.CustomSteps
+		}
+		pipeline, err := buildPipeline(p)
+		if err != nil {
+			return nil, fmt.Errorf("配置档 %s: %v", p.Name, err)
+		}
+		p.pipeline = pipeline
+
+		if p.DirPermission == "" {
+			p.DirPermission = config.DirPermission
+		}
+		if p.FilePermission == "" {
+			p.FilePermission = config.FilePermission
+		}
+		if p.ExecPermission == "" {
+			p.ExecPermission = config.ExecPermission
+		}
+		if len(p.AcceptTypes) == 0 {
+			p.AcceptTypes = config.AcceptTypes
+		}
+		if p.ManifestFile == "" {
+			p.ManifestFile = config.ManifestFile
+		}
+		if len(p.VersionCommand) == 0 {
+			p.VersionCommand = config.VersionCommand
+		}
+		if p.DowngradePolicy == "" {
+			p.DowngradePolicy = config.DowngradePolicy
+		}
+		if p.SameVersionPolicy == "" {
+			p.SameVersionPolicy = config.SameVersionPolicy
+		}
+		if len(p.Channels) == 0 {
+			p.Channels = config.Channels
+		}
+		if p.Channel == "" {
+			p.Channel = config.Channel
+		}
+		if !slices.Contains(p.Channels, p.Channel) {
+			return nil, fmt.Errorf("配置档 %s: 发布渠道 %s 不在 channels 中", p.Name, p.Channel)
+		}
+	}
+
+	return list, nil
+}
+
+func (p *Profile) historyPath() string {
+	return filepath.Join(p.stateDir(), "history.json")
+}
+
+// 升级历史（最新的在前）
+func (p *Profile) History() []HistoryEntry {
+	p.histMu.Lock()
+	defer p.histMu.Unlock()
+	return p.readHistory()
+}
+
+func (p *Profile) readHistory() []HistoryEntry {
+	data, err := os.ReadFile(p.historyPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[%s] 读取升级历史失败: %v", p.Name, err)
+		}
+		return nil
+	}
+
+	var history []HistoryEntry
+	if err := json.Unmarshal(data, &history); err != nil {
+		log.Printf("[%s] 解析升级历史失败: %v", p.Name, err)
+		return nil
+	}
+	return history
+}
+
+// 追加升级历史，超过上限时丢弃最旧的记录
+func (p *Profile) addHistory(entry HistoryEntry) {
+	p.histMu.Lock()
+	defer p.histMu.Unlock()
+
+	history := append([]HistoryEntry{entry}, p.readHistory()...)
+	if limit := p.engine.config.HistoryLimit; limit > 0 && len(history) > limit {
+		history = history[:limit]
+	}
+
+	if err := writeJSONFile(p.historyPath(), history, ParsePermission(p.DirPermission)); err != nil {
+		log.Printf("[%s] 保存升级历史失败: %v", p.Name, err)
+	}
+}
+
+// 原子写入 JSON 文件：先写临时文件再重命名，避免断电时留下半个文件
+func writeJSONFile(path string, v any, dirPerm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化失败: %v", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
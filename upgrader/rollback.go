@@ -0,0 +1,227 @@
+package upgrader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 备份目标目录，备份目录位于目标目录内时将其排除。
+// 当前的部署记录保存在备份旁边，从备份恢复时一并恢复
+func (p *Profile) createBackup(ctx context.Context, logs *strings.Builder) (string, error) {
+	backupPath := filepath.Join(p.BackupDir, fmt.Sprintf("backup_%s.tar.gz", time.Now().Format("20060102_150405")))
+
+	args := []string{"-czf", backupPath, "-C", p.TargetDir}
+	if rel, ok := p.backupDirInTarget(); ok {
+		args = append(args, "--exclude=./"+rel)
+	}
+	args = append(args, ".")
+
+	ctx, cancel := withTimeout(ctx, p.Timeouts.Backup)
+	defer cancel()
+	if err := runCommand(ctx, "tar", args...); err != nil {
+		os.Remove(backupPath)
+		return "", err
+	}
+	if record := p.Deployed(); record != nil {
+		if err := writeJSONFile(backupRecordPath(backupPath), record, ParsePermission(p.DirPermission)); err != nil {
+			logs.WriteString(fmt.Sprintf("   警告: 保存备份的版本记录失败: %v\n", err))
+		}
+	}
+	return backupPath, nil
+}
+
+// 备份对应的部署记录
+func backupRecordPath(backupPath string) string {
+	return backupPath + ".json"
+}
+
+// 读取备份对应的部署记录，旧版本创建的备份没有记录时返回 nil
+func loadBackupRecord(backupPath string) *DeployRecord {
+	data, err := os.ReadFile(backupRecordPath(backupPath))
+	if err != nil {
+		return nil
+	}
+	var record DeployRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		log.Printf("解析备份的版本记录失败: %s: %v", backupPath, err)
+		return nil
+	}
+	return &record
+}
+
+// 备份目录相对目标目录的路径（仅当位于目标目录内时）
+func (p *Profile) backupDirInTarget() (string, bool) {
+	rel, err := filepath.Rel(p.TargetDir, p.BackupDir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// 从备份恢复：停止服务、清空目标目录（保留备份目录）、解压备份、恢复部署记录并启动服务
+func (p *Profile) restoreBackup(ctx context.Context, backupPath string, previous *DeployRecord, logs *strings.Builder) error {
+	logs.WriteString(fmt.Sprintf("   从备份恢复: %s\n", backupPath))
+	if _, err := os.Stat(backupPath); err != nil {
+		return fmt.Errorf("备份文件不可用: %v", err)
+	}
+
+	if p.ServiceEnabled() {
+		if err := p.stopService(ctx, logs); err != nil {
+			logs.WriteString(fmt.Sprintf("   警告: 停止服务失败: %v\n", err))
+		}
+	}
+
+	if err := p.cleanTargetDir(); err != nil {
+		return fmt.Errorf("清理目标目录失败: %v", err)
+	}
+	extractCtx, cancel := withTimeout(ctx, p.Timeouts.Deploy)
+	defer cancel()
+	if err := runCommand(extractCtx, "tar", "-xzf", backupPath, "-C", p.TargetDir); err != nil {
+		return fmt.Errorf("解压备份失败: %v", err)
+	}
+	if err := setPermissions(p, logs); err != nil {
+		return err
+	}
+	logs.WriteString("   ✓ 程序文件已恢复\n")
+
+	if previous != nil {
+		if err := p.saveDeployRecord(previous); err != nil {
+			logs.WriteString(fmt.Sprintf("   警告: %v\n", err))
+		} else {
+			logs.WriteString(fmt.Sprintf("   ✓ 版本记录已恢复为 %s\n", displayVersion(previous.Version)))
+		}
+	}
+
+	if p.ServiceEnabled() {
+		if err := p.startService(ctx); err != nil {
+			return fmt.Errorf("恢复后启动服务失败: %v", err)
+		}
+		logs.WriteString("   ✓ 服务已重新启动\n")
+	}
+
+	log.Printf("[%s] 已从备份恢复: %s", p.Name, backupPath)
+	return nil
+}
+
+// 删除目标目录下除备份目录外的所有内容
+func (p *Profile) cleanTargetDir() error {
+	if filepath.Clean(p.TargetDir) == "/" {
+		return fmt.Errorf("拒绝清理根目录")
+	}
+
+	keep := ""
+	if rel, ok := p.backupDirInTarget(); ok {
+		keep, _, _ = strings.Cut(rel, "/")
+	}
+
+	entries, err := os.ReadDir(p.TargetDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == keep {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(p.TargetDir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 升级验证失败后的自动回滚
+func (p *Profile) rollbackAfterFailure(ctx context.Context, backupPath string, previous *DeployRecord, cause error, remote string, logs *strings.Builder) error {
+	logs.WriteString("\n自动回滚...\n")
+	audit := AuditEntry{Action: "rollback", Profile: p.Name, Remote: remote, Message: cause.Error(), Result: "success"}
+	if previous != nil {
+		audit.ToVersion = previous.Version
+	}
+	if record := p.Deployed(); record != nil {
+		audit.FromVersion = record.Version
+	}
+
+	if backupPath == "" {
+		logs.WriteString("   ✗ 没有可用的备份，无法回滚\n")
+		audit.Result = "failed"
+		p.engine.writeAudit(audit)
+		return fmt.Errorf("%v，且没有可用的备份，无法回滚", cause)
+	}
+
+	if err := p.restoreBackup(ctx, backupPath, previous, logs); err != nil {
+		logs.WriteString(fmt.Sprintf("   ✗ 回滚失败: %v\n", err))
+		audit.Result = "failed"
+		p.engine.writeAudit(audit)
+		return fmt.Errorf("%v，回滚失败: %v", cause, err)
+	}
+
+	logs.WriteString("   ✓ 已回滚到升级前的版本\n")
+	p.engine.writeAudit(audit)
+	return fmt.Errorf("%v，已回滚到升级前的版本", cause)
+}
+
+// 备份文件信息
+type BackupInfo struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	Created time.Time `json:"created"`
+}
+
+// 备份目录中的备份（最新的在前）
+func (p *Profile) listBackups() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(p.BackupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []BackupInfo
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "backup_") || !strings.HasSuffix(name, ".tar.gz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{
+			Name:    name,
+			Path:    filepath.Join(p.BackupDir, name),
+			Size:    info.Size(),
+			Created: info.ModTime(),
+		})
+	}
+	// 文件名包含时间戳，按名称倒序即按时间倒序
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// 按文件名查找备份，名称为空时返回最新的备份
+func (p *Profile) findBackup(name string) (BackupInfo, error) {
+	backups, err := p.listBackups()
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("读取备份目录失败: %v", err)
+	}
+	if len(backups) == 0 {
+		return BackupInfo{}, fmt.Errorf("没有可用的备份")
+	}
+	if name == "" {
+		return backups[0], nil
+	}
+	for _, b := range backups {
+		if b.Name == name {
+			return b, nil
+		}
+	}
+	return BackupInfo{}, fmt.Errorf("备份不存在: %s", name)
+}
@@ -0,0 +1,312 @@
+package upgrader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 服务管理后端
+const (
+	ServiceManagerSystemd    = "systemd"
+	ServiceManagerOpenRC     = "openrc"
+	ServiceManagerSysV       = "sysv"
+	ServiceManagerSupervisor = "supervisor"
+	ServiceManagerCustom     = "custom"
+	ServiceManagerBuiltin    = "builtin"
+)
+
+// 服务管理器：屏蔽不同 init 系统的差异
+type ServiceManager interface {
+	Name() string
+	Stop(ctx context.Context, service string) error
+	Start(ctx context.Context, service string) error
+	Restart(ctx context.Context, service string) error
+	// Status 返回 nil 表示服务正在运行
+	Status(ctx context.Context, service string) error
+}
+
+// 能够强制终止服务的服务管理器，停止服务超时时使用
+type serviceKiller interface {
+	Kill(ctx context.Context, service string) error
+}
+
+// 自定义服务命令，参数中的 {service} 会被替换为服务名
+type ServiceCommands struct {
+	Stop    []string `json:"stop"`
+	Start   []string `json:"start"`
+	Restart []string `json:"restart"`
+	Status  []string `json:"status"`
+	Kill    []string `json:"kill"` // 可选，停止超时时执行
+}
+
+// 根据配置档创建服务管理器
+func newServiceManager(p *Profile) (ServiceManager, error) {
+	commands := p.ServiceCommands
+	switch p.ServiceManager {
+	case "", ServiceManagerSystemd:
+		return systemdManager{}, nil
+	case ServiceManagerOpenRC:
+		return openrcManager{}, nil
+	case ServiceManagerSysV:
+		return sysvManager{}, nil
+	case ServiceManagerSupervisor:
+		return supervisorManager{}, nil
+	case ServiceManagerCustom:
+		if commands == nil || len(commands.Stop) == 0 || len(commands.Start) == 0 {
+			return nil, fmt.Errorf("custom 服务管理器至少需要配置 stop 和 start 命令")
+		}
+		manager := customManager{commands: *commands}
+		if len(commands.Kill) > 0 {
+			return killableCustomManager{manager}, nil
+		}
+		return manager, nil
+	case ServiceManagerBuiltin:
+		return newProcessSupervisor(p)
+	default:
+		return nil, fmt.Errorf("未知的服务管理器: %s", p.ServiceManager)
+	}
+}
+
+// systemd: systemctl
+type systemdManager struct{}
+
+func (systemdManager) Name() string {
+	return ServiceManagerSystemd
+}
+
+func (systemdManager) Stop(ctx context.Context, service string) error {
+	return runCommand(ctx, "systemctl", "stop", service)
+}
+
+func (systemdManager) Start(ctx context.Context, service string) error {
+	return runCommand(ctx, "systemctl", "start", service)
+}
+
+func (systemdManager) Restart(ctx context.Context, service string) error {
+	return runCommand(ctx, "systemctl", "restart", service)
+}
+
+func (systemdManager) Status(ctx context.Context, service string) error {
+	return runCommand(ctx, "systemctl", "is-active", "--quiet", service)
+}
+
+func (systemdManager) Kill(ctx context.Context, service string) error {
+	return runCommand(ctx, "systemctl", "kill", "--signal=SIGKILL", service)
+}
+
+// OpenRC: rc-service
+type openrcManager struct{}
+
+func (openrcManager) Name() string {
+	return ServiceManagerOpenRC
+}
+
+func (openrcManager) Stop(ctx context.Context, service string) error {
+	return runCommand(ctx, "rc-service", service, "stop")
+}
+
+func (openrcManager) Start(ctx context.Context, service string) error {
+	return runCommand(ctx, "rc-service", service, "start")
+}
+
+func (openrcManager) Restart(ctx context.Context, service string) error {
+	return runCommand(ctx, "rc-service", service, "restart")
+}
+
+func (openrcManager) Status(ctx context.Context, service string) error {
+	return runCommand(ctx, "rc-service", service, "status")
+}
+
+// SysV: /etc/init.d 脚本，不存在时退回 service 命令
+type sysvManager struct{}
+
+func (sysvManager) Name() string {
+	return ServiceManagerSysV
+}
+
+func (m sysvManager) Stop(ctx context.Context, service string) error {
+	return m.run(ctx, service, "stop")
+}
+
+func (m sysvManager) Start(ctx context.Context, service string) error {
+	return m.run(ctx, service, "start")
+}
+
+func (m sysvManager) Restart(ctx context.Context, service string) error {
+	return m.run(ctx, service, "restart")
+}
+
+func (m sysvManager) Status(ctx context.Context, service string) error {
+	return m.run(ctx, service, "status")
+}
+
+func (sysvManager) run(ctx context.Context, service, action string) error {
+	script := filepath.Join("/etc/init.d", service)
+	if _, err := os.Stat(script); err == nil {
+		return runCommand(ctx, script, action)
+	}
+	return runCommand(ctx, "service", service, action)
+}
+
+// supervisord: supervisorctl
+type supervisorManager struct{}
+
+func (supervisorManager) Name() string {
+	return ServiceManagerSupervisor
+}
+
+func (supervisorManager) Stop(ctx context.Context, service string) error {
+	return runCommand(ctx, "supervisorctl", "stop", service)
+}
+
+func (supervisorManager) Start(ctx context.Context, service string) error {
+	return runCommand(ctx, "supervisorctl", "start", service)
+}
+
+func (supervisorManager) Restart(ctx context.Context, service string) error {
+	return runCommand(ctx, "supervisorctl", "restart", service)
+}
+
+// supervisorctl status 在进程非 RUNNING 状态时返回非零退出码
+func (supervisorManager) Status(ctx context.Context, service string) error {
+	return runCommand(ctx, "supervisorctl", "status", service)
+}
+
+func (supervisorManager) Kill(ctx context.Context, service string) error {
+	return runCommand(ctx, "supervisorctl", "signal", "KILL", service)
+}
+
+// 自定义命令
+type customManager struct {
+	commands ServiceCommands
+}
+
+func (customManager) Name() string {
+	return ServiceManagerCustom
+}
+
+func (m customManager) Stop(ctx context.Context, service string) error {
+	return m.run(ctx, m.commands.Stop, service)
+}
+
+func (m customManager) Start(ctx context.Context, service string) error {
+	return m.run(ctx, m.commands.Start, service)
+}
+
+func (m customManager) Status(ctx context.Context, service string) error {
+	return m.run(ctx, m.commands.Status, service)
+}
+
+// 未配置 restart 命令时依次执行 stop 与 start
+func (m customManager) Restart(ctx context.Context, service string) error {
+	if len(m.commands.Restart) == 0 {
+		if err := m.Stop(ctx, service); err != nil {
+			return err
+		}
+		return m.Start(ctx, service)
+	}
+	return m.run(ctx, m.commands.Restart, service)
+}
+
+// 配置了 kill 命令的自定义命令，只有它实现 serviceKiller，
+// 未配置时停止超时直接报错，而不是当作已强制终止
+type killableCustomManager struct {
+	customManager
+}
+
+func (m killableCustomManager) Kill(ctx context.Context, service string) error {
+	return m.run(ctx, m.commands.Kill, service)
+}
+
+func (customManager) run(ctx context.Context, command []string, service string) error {
+	if len(command) == 0 {
+		return fmt.Errorf("未配置该操作的命令")
+	}
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = strings.ReplaceAll(arg, "{service}", service)
+	}
+	return runCommand(ctx, args[0], args[1:]...)
+}
+
+// 停止服务，超过 timeouts.stop 后强制终止
+func (p *Profile) stopService(ctx context.Context, logs *strings.Builder) error {
+	stopCtx, cancel := withTimeout(ctx, p.Timeouts.Stop)
+	defer cancel()
+
+	err := p.svc.Stop(stopCtx, p.ServiceName)
+	if err == nil || stopCtx.Err() != context.DeadlineExceeded || ctx.Err() != nil {
+		return err
+	}
+
+	killer, ok := p.svc.(serviceKiller)
+	if !ok {
+		return fmt.Errorf("停止服务超过 %d 秒，%s 不支持强制终止", p.Timeouts.Stop, p.svc.Name())
+	}
+	logs.WriteString(fmt.Sprintf("   停止服务超过 %d 秒，强制终止...\n", p.Timeouts.Stop))
+	killCtx, cancel := withTimeout(ctx, p.Timeouts.Command)
+	defer cancel()
+	if err := killer.Kill(killCtx, p.ServiceName); err != nil {
+		return fmt.Errorf("停止服务超时，强制终止失败: %v", err)
+	}
+	logs.WriteString("   ✓ 服务已强制终止\n")
+	return nil
+}
+
+func (p *Profile) startService(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, p.Timeouts.Start)
+	defer cancel()
+	return p.svc.Start(ctx, p.ServiceName)
+}
+
+func (p *Profile) serviceStatus(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, p.Timeouts.Command)
+	defer cancel()
+	return p.svc.Status(ctx, p.ServiceName)
+}
+
+// 执行服务操作，升级进行中时拒绝；返回命令输出
+func (p *Profile) controlService(ctx context.Context, action, remote string) (string, error) {
+	if !p.ServiceEnabled() {
+		return "", fmt.Errorf("配置档 %s 未启用服务管理", p.Name)
+	}
+
+	var logs strings.Builder
+	ctx = withCommandLog(context.WithoutCancel(ctx), &logs)
+
+	var fn func() error
+	switch action {
+	case "start":
+		fn = func() error { return p.startService(ctx) }
+	case "stop":
+		fn = func() error { return p.stopService(ctx, &logs) }
+	case "restart":
+		fn = func() error {
+			ctx, cancel := withTimeout(ctx, p.Timeouts.Stop+p.Timeouts.Start)
+			defer cancel()
+			return p.svc.Restart(ctx, p.ServiceName)
+		}
+	default:
+		return "", fmt.Errorf("不支持的操作: %s", action)
+	}
+
+	if !p.lock.TryLock() {
+		return "", fmt.Errorf("配置档 %s: %w", p.Name, ErrBusy)
+	}
+	defer p.lock.Unlock()
+
+	log.Printf("[%s] 服务操作: %s %s (%s)", p.Name, action, p.ServiceName, p.svc.Name())
+	err := fn()
+
+	audit := AuditEntry{Action: "service_" + action, Profile: p.Name, Remote: remote, Result: "success"}
+	if err != nil {
+		audit.Result = "failed"
+		audit.Message = err.Error()
+	}
+	p.engine.writeAudit(audit)
+	return logs.String(), err
+}
@@ -0,0 +1,82 @@
+package upgrader
+
+import (
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stop 命令卡住时，超过 timeouts.stop 后执行 kill 命令终止服务进程
+func TestStopServiceKill(t *testing.T) {
+	tests := []struct {
+		name   string
+		kill   bool
+		killed bool
+	}{
+		{"配置了 kill", true, true},
+		{"未配置 kill", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 被管理的服务进程
+			service := exec.Command("sleep", "60")
+			if err := service.Start(); err != nil {
+				t.Fatal(err)
+			}
+			exited := make(chan struct{})
+			go func() {
+				service.Wait()
+				close(exited)
+			}()
+			defer func() {
+				service.Process.Kill()
+				<-exited
+			}()
+
+			commands := ServiceCommands{Stop: []string{"sleep", "60"}, Start: []string{"true"}}
+			if tt.kill {
+				commands.Kill = []string{"kill", "-KILL", strconv.Itoa(service.Process.Pid)}
+			}
+			p := &Profile{}
+			p.ServiceName = "app"
+			p.ServiceManager = ServiceManagerCustom
+			p.ServiceCommands = &commands
+			p.Timeouts = &CommandTimeouts{Stop: 1, Command: 5}
+			var err error
+			if p.svc, err = newServiceManager(p); err != nil {
+				t.Fatal(err)
+			}
+
+			var logs strings.Builder
+			started := time.Now()
+			err = p.stopService(context.Background(), &logs)
+			if elapsed := time.Since(started); elapsed > 5*time.Second {
+				t.Errorf("停止耗时 %v，卡住的 stop 命令应在超时后终止", elapsed)
+			}
+			if (err == nil) != tt.killed {
+				t.Errorf("stopService = %v, logs:\n%s", err, logs.String())
+			}
+			if !tt.kill && (err == nil || !strings.Contains(err.Error(), "不支持强制终止")) {
+				t.Errorf("未配置 kill 时应报告不支持强制终止: %v", err)
+			}
+
+			select {
+			case <-exited:
+				if !tt.killed {
+					t.Error("未配置 kill 时服务进程不应被终止")
+				}
+				if status, ok := service.ProcessState.Sys().(syscall.WaitStatus); !ok || status.Signal() != syscall.SIGKILL {
+					t.Errorf("服务进程退出状态 %v", service.ProcessState)
+				}
+			case <-time.After(2 * time.Second):
+				if tt.killed {
+					t.Error("超时后服务进程未被终止")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package upgrader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 内置进程守护配置，用于没有 init 系统的主机
+type ProcessConfig struct {
+	Command         string   `json:"command"`           // 可执行文件，相对路径基于 target_dir
+	Args            []string `json:"args"`              // 启动参数
+	Env             []string `json:"env"`               // 额外环境变量，KEY=VALUE
+	WorkDir         string   `json:"work_dir"`          // 工作目录，默认 target_dir
+	StopTimeout     int      `json:"stop_timeout"`      // SIGTERM 后等待退出的时间（秒），超时发送 SIGKILL
+	RestartDelay    int      `json:"restart_delay"`     // 崩溃后首次重启等待（秒），之后指数退避
+	MaxRestartDelay int      `json:"max_restart_delay"` // 重启等待上限（秒）
+	LogLines        int      `json:"log_lines"`         // 内存中保留的输出行数
+}
+
+// 进程输出行
+type outputLine struct {
+	Time   time.Time
+	Stream string
+	Text   string
+}
+
+// 保存最近的进程输出，同时转发到程序日志
+type outputBuffer struct {
+	name string
+	max  int
+
+	mu      sync.Mutex
+	lines   []outputLine
+	partial map[string]string
+}
+
+func newOutputBuffer(name string, max int) *outputBuffer {
+	return &outputBuffer{name: name, max: max, partial: make(map[string]string)}
+}
+
+func (b *outputBuffer) writer(stream string) *outputWriter {
+	return &outputWriter{buf: b, stream: stream}
+}
+
+func (b *outputBuffer) write(stream string, p []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := b.partial[stream] + string(p)
+	for {
+		line, rest, found := strings.Cut(data, "\n")
+		if !found {
+			break
+		}
+		b.appendLocked(stream, line)
+		data = rest
+	}
+	b.partial[stream] = data
+}
+
+func (b *outputBuffer) appendLocked(stream, text string) {
+	log.Printf("[%s:%s] %s", b.name, stream, text)
+	b.lines = append(b.lines, outputLine{Time: time.Now(), Stream: stream, Text: text})
+	if over := len(b.lines) - b.max; over > 0 {
+		b.lines = append(b.lines[:0], b.lines[over:]...)
+	}
+}
+
+// 返回 since 之后的最后 n 行
+func (b *outputBuffer) tail(since time.Time, n int) []outputLine {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var lines []outputLine
+	for _, line := range b.lines {
+		if !line.Time.Before(since) {
+			lines = append(lines, line)
+		}
+	}
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+type outputWriter struct {
+	buf    *outputBuffer
+	stream string
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.buf.write(w.stream, p)
+	return len(p), nil
+}
+
+// 内置进程守护：直接启动目标程序，崩溃后按退避时间重启
+type processSupervisor struct {
+	name string
+	cfg  ProcessConfig
+	dir  string
+
+	output *outputBuffer
+
+	mu        sync.Mutex
+	want      bool          // 期望运行
+	cmd       *exec.Cmd     // 当前进程
+	exited    chan struct{} // 当前进程退出时关闭
+	stop      chan struct{} // Stop 时关闭，用于打断退避等待
+	startedAt time.Time
+	restarts  int
+	lastExit  string
+}
+
+func newProcessSupervisor(p *Profile) (*processSupervisor, error) {
+	if p.Process == nil || p.Process.Command == "" {
+		return nil, fmt.Errorf("builtin 服务管理器需要配置 process.command")
+	}
+
+	cfg := *p.Process
+	if cfg.StopTimeout <= 0 {
+		cfg.StopTimeout = 10
+	}
+	if cfg.RestartDelay <= 0 {
+		cfg.RestartDelay = 1
+	}
+	if cfg.MaxRestartDelay < cfg.RestartDelay {
+		cfg.MaxRestartDelay = 60
+	}
+	if cfg.LogLines <= 0 {
+		cfg.LogLines = 500
+	}
+
+	return &processSupervisor{
+		name:   p.Name,
+		cfg:    cfg,
+		dir:    p.TargetDir,
+		output: newOutputBuffer(p.Name, cfg.LogLines),
+	}, nil
+}
+
+func (s *processSupervisor) Name() string {
+	return ServiceManagerBuiltin
+}
+
+// 启动进程并开始守护
+func (s *processSupervisor) Start(ctx context.Context, service string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.want {
+		if s.cmd != nil {
+			return nil
+		}
+		// 正在退避等待，结束旧的守护循环后立即启动
+		close(s.stop)
+		s.stop = nil
+		s.want = false
+	}
+	if err := s.launchLocked(); err != nil {
+		return err
+	}
+
+	// 与 systemd 的 NRestarts 一致，手动启动时重置重启计数
+	s.restarts = 0
+	s.want = true
+	s.stop = make(chan struct{})
+	go s.supervise(s.stop)
+	return nil
+}
+
+// 停止进程：先发送 SIGTERM，超过 stop_timeout 或 ctx 结束后发送 SIGKILL
+func (s *processSupervisor) Stop(ctx context.Context, service string) error {
+	s.mu.Lock()
+	s.want = false
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+	cmd, exited := s.cmd, s.exited
+	s.mu.Unlock()
+
+	if cmd == nil {
+		return nil
+	}
+
+	log.Printf("[%s] 发送 SIGTERM 到进程 %d", s.name, cmd.Process.Pid)
+	signalGroup(cmd, syscall.SIGTERM)
+
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(time.Duration(s.cfg.StopTimeout) * time.Second):
+	case <-ctx.Done():
+	}
+
+	log.Printf("[%s] 进程 %d 未及时退出，发送 SIGKILL", s.name, cmd.Process.Pid)
+	signalGroup(cmd, syscall.SIGKILL)
+	<-exited
+	return nil
+}
+
+func (s *processSupervisor) Restart(ctx context.Context, service string) error {
+	if err := s.Stop(ctx, service); err != nil {
+		return err
+	}
+	return s.Start(ctx, service)
+}
+
+func (s *processSupervisor) Status(ctx context.Context, service string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cmd != nil {
+		return nil
+	}
+	if s.lastExit != "" {
+		return fmt.Errorf("进程未运行 (上次退出: %s)", s.lastExit)
+	}
+	return fmt.Errorf("进程未运行")
+}
+
+// 启动一次进程，调用方需持有 s.mu
+func (s *processSupervisor) launchLocked() error {
+	command := s.cfg.Command
+	if !filepath.IsAbs(command) && strings.ContainsRune(command, filepath.Separator) {
+		command = filepath.Join(s.dir, command)
+	} else if !filepath.IsAbs(command) {
+		if _, err := os.Stat(filepath.Join(s.dir, command)); err == nil {
+			command = filepath.Join(s.dir, command)
+		}
+	}
+
+	cmd := exec.Command(command, s.cfg.Args...)
+	cmd.Dir = s.cfg.WorkDir
+	if cmd.Dir == "" {
+		cmd.Dir = s.dir
+	}
+	cmd.Env = append(os.Environ(), s.cfg.Env...)
+	cmd.Stdout = s.output.writer("stdout")
+	cmd.Stderr = s.output.writer("stderr")
+	// 独立进程组，停止时连同子进程一起发送信号；升级器退出时进程随之终止
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pdeathsig: syscall.SIGTERM}
+	// 子进程持有输出管道时不无限等待
+	cmd.WaitDelay = time.Second
+
+	// Pdeathsig 在创建子进程的线程退出时触发，而不是在升级器退出时，
+	// 因此在独占线程的 goroutine 中启动并等待进程，进程退出前该线程不会退出
+	exited := make(chan struct{})
+	started := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := cmd.Start(); err != nil {
+			started <- err
+			return
+		}
+		started <- nil
+
+		err := cmd.Wait()
+		s.mu.Lock()
+		if err != nil {
+			s.lastExit = err.Error()
+		} else {
+			s.lastExit = "exit status 0"
+		}
+		if s.cmd == cmd {
+			s.cmd = nil
+		}
+		s.mu.Unlock()
+		close(exited)
+	}()
+	if err := <-started; err != nil {
+		return fmt.Errorf("启动进程失败: %v", err)
+	}
+
+	s.cmd = cmd
+	s.exited = exited
+	s.startedAt = time.Now()
+	log.Printf("[%s] 已启动进程 %d: %s %s", s.name, cmd.Process.Pid, command, strings.Join(s.cfg.Args, " "))
+	return nil
+}
+
+// 守护循环：进程意外退出后按指数退避重启
+func (s *processSupervisor) supervise(stop chan struct{}) {
+	delay := time.Duration(s.cfg.RestartDelay) * time.Second
+	maxDelay := time.Duration(s.cfg.MaxRestartDelay) * time.Second
+
+	for {
+		s.mu.Lock()
+		exited, startedAt := s.exited, s.startedAt
+		s.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-exited:
+		}
+
+		// 稳定运行超过退避上限后重置退避时间
+		if time.Since(startedAt) > maxDelay {
+			delay = time.Duration(s.cfg.RestartDelay) * time.Second
+		}
+
+		s.mu.Lock()
+		if !s.want || s.stop != stop {
+			s.mu.Unlock()
+			return
+		}
+		s.restarts++
+		log.Printf("[%s] 进程意外退出 (%s)，%v 后重启", s.name, s.lastExit, delay)
+		s.mu.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+
+		s.mu.Lock()
+		if !s.want || s.stop != stop {
+			s.mu.Unlock()
+			return
+		}
+		if err := s.launchLocked(); err != nil {
+			log.Printf("[%s] %v", s.name, err)
+			// 启动失败时视为立即退出，继续退避重试
+			closed := make(chan struct{})
+			close(closed)
+			s.exited = closed
+			s.startedAt = time.Now()
+		}
+		s.mu.Unlock()
+	}
+}
+
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) {
+	if err := syscall.Kill(-cmd.Process.Pid, sig); err != nil {
+		cmd.Process.Signal(sig)
+	}
+}
@@ -0,0 +1,282 @@
+package upgrader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 版本来源
+const (
+	VersionSourceManifest = "manifest"
+	VersionSourceCommand  = "command"
+	VersionSourceFilename = "filename"
+	VersionSourceUnknown  = "unknown"
+)
+
+// 清单文件大小上限，避免读取异常的大文件
+const maxManifestSize = 64 << 10
+
+// 部署记录：描述当前正在运行的程序版本
+type DeployRecord struct {
+	Version       string    `json:"version"`
+	VersionSource string    `json:"version_source"`
+	Filename      string    `json:"filename"`
+	SHA256        string    `json:"sha256"`
+	DeployedAt    time.Time `json:"deployed_at"`
+}
+
+// 匹配 1.2.3 / v1.2.3 / 1.2.3-rc.1+build.5 形式的版本号
+var versionPattern = regexp.MustCompile(`v?\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?`)
+
+// 用于显示的版本号
+func (r *DeployRecord) DisplayVersion() string {
+	return displayVersion(r.Version)
+}
+
+// 获取当前部署记录（可能为 nil）
+func (p *Profile) Deployed() *DeployRecord {
+	p.deployMu.RLock()
+	defer p.deployMu.RUnlock()
+	return p.deploy
+}
+
+func (p *Profile) deployStatePath() string {
+	return filepath.Join(p.stateDir(), "deployed.json")
+}
+
+// 启动时加载上一次的部署记录
+func (p *Profile) loadDeployRecord() {
+	data, err := os.ReadFile(p.deployStatePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[%s] 读取部署记录失败: %v", p.Name, err)
+		}
+		return
+	}
+
+	var record DeployRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		log.Printf("[%s] 解析部署记录失败: %v", p.Name, err)
+		return
+	}
+
+	p.deployMu.Lock()
+	p.deploy = &record
+	p.deployMu.Unlock()
+}
+
+// 保存部署记录
+func (p *Profile) saveDeployRecord(record *DeployRecord) error {
+	if err := writeJSONFile(p.deployStatePath(), record, ParsePermission(p.DirPermission)); err != nil {
+		return fmt.Errorf("写入部署记录失败: %v", err)
+	}
+
+	p.deployMu.Lock()
+	p.deploy = record
+	p.deployMu.Unlock()
+	return nil
+}
+
+// 识别已部署的版本：优先使用安装包自身的版本（包内清单文件或文件名），
+// 安装包不带版本时再读取目标目录的清单文件或运行版本命令。
+// 目标目录的清单文件可能是上一版本遗留的，不能优先于安装包
+func (p *Profile) detectDeployedVersion(ctx context.Context, filePath, filename string) (string, string) {
+	if version, source := p.detectPackageVersion(filePath, filename); version != "" {
+		return version, source
+	}
+
+	if p.ManifestFile != "" {
+		if version := readManifestVersion(filepath.Join(p.TargetDir, p.ManifestFile)); version != "" {
+			return version, VersionSourceManifest
+		}
+	}
+
+	if len(p.VersionCommand) > 0 {
+		ctx, cancel := withTimeout(ctx, p.Timeouts.Command)
+		out, err := newCommand(ctx, p.VersionCommand[0], p.VersionCommand[1:]...).Output()
+		cancel()
+		if err == nil {
+			if version := parseVersionOutput(string(out)); version != "" {
+				return version, VersionSourceCommand
+			}
+		}
+	}
+
+	return "", VersionSourceUnknown
+}
+
+// 识别待升级安装包的版本：优先读取包内清单文件，其次使用文件名
+func (p *Profile) detectPackageVersion(filePath, filename string) (string, string) {
+	if p.ManifestFile != "" {
+		if version := readPackageManifest(filePath, filename, p.ManifestFile); version != "" {
+			return version, VersionSourceManifest
+		}
+	}
+
+	if version := versionFromFilename(filename); version != "" {
+		return version, VersionSourceFilename
+	}
+
+	return "", VersionSourceUnknown
+}
+
+// 从 tar.gz / zip 安装包中读取清单文件，不解压到磁盘
+func readPackageManifest(filePath, filename, manifest string) string {
+	want := path.Clean(strings.TrimPrefix(manifest, "./"))
+	lower := strings.ToLower(filename)
+
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		f, err := os.Open(filePath)
+		if err != nil {
+			return ""
+		}
+		defer f.Close()
+
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return ""
+		}
+		defer gz.Close()
+
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				return ""
+			}
+			if hdr.Typeflag == tar.TypeReg && path.Clean(strings.TrimPrefix(hdr.Name, "./")) == want {
+				data, err := io.ReadAll(io.LimitReader(tr, maxManifestSize))
+				if err != nil {
+					return ""
+				}
+				return parseManifest(data)
+			}
+		}
+	case strings.HasSuffix(lower, ".zip"):
+		zr, err := zip.OpenReader(filePath)
+		if err != nil {
+			return ""
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			if path.Clean(strings.TrimPrefix(f.Name, "./")) != want {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return ""
+			}
+			data, err := io.ReadAll(io.LimitReader(rc, maxManifestSize))
+			rc.Close()
+			if err != nil {
+				return ""
+			}
+			return parseManifest(data)
+		}
+	}
+
+	return ""
+}
+
+// 读取清单文件中的版本号，支持 JSON ({"version": "..."}) 或纯文本
+func readManifestVersion(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return parseManifest(data)
+}
+
+func parseManifest(data []byte) string {
+	var manifest struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &manifest); err == nil {
+		return strings.TrimSpace(manifest.Version)
+	}
+
+	return parseVersionOutput(string(data))
+}
+
+// 从命令输出或文本中提取版本号，找不到时取第一行
+func parseVersionOutput(out string) string {
+	if match := versionPattern.FindString(out); match != "" {
+		return match
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(line)
+}
+
+func versionFromFilename(filename string) string {
+	return versionPattern.FindString(filepath.Base(filename))
+}
+
+// 计算文件的 SHA256
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 部署完成后记录版本信息，hash 为空时计算文件的 SHA256
+func (p *Profile) recordDeployment(ctx context.Context, filePath, filename, hash string, logs *strings.Builder) {
+	if hash == "" {
+		var err error
+		if hash, err = hashFile(filePath); err != nil {
+			logs.WriteString(fmt.Sprintf("   警告: 计算文件哈希失败: %v\n", err))
+		}
+	}
+
+	version, source := p.detectDeployedVersion(ctx, filePath, filename)
+	record := &DeployRecord{
+		Version:       version,
+		VersionSource: source,
+		Filename:      filename,
+		SHA256:        hash,
+		DeployedAt:    time.Now(),
+	}
+
+	if err := p.saveDeployRecord(record); err != nil {
+		logs.WriteString(fmt.Sprintf("   警告: %v\n", err))
+		return
+	}
+
+	if version == "" {
+		logs.WriteString("   警告: 未能识别版本号\n")
+	} else {
+		logs.WriteString(fmt.Sprintf("   ✓ 版本: %s (来源: %s)\n", version, source))
+	}
+	logs.WriteString(fmt.Sprintf("   ✓ SHA256: %s\n", hash))
+	log.Printf("[%s] 已部署版本: %s (来源: %s), 文件: %s, SHA256: %s", p.Name, displayVersion(version), source, filename, hash)
+}
+
+func displayVersion(version string) string {
+	if version == "" {
+		return "未知"
+	}
+	return version
+}
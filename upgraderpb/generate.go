@@ -0,0 +1,4 @@
+// Package upgraderpb 是 proto/upgrader.proto 生成的 gRPC 接口代码
+package upgraderpb
+
+//go:generate protoc -I ../proto --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative upgrader.proto
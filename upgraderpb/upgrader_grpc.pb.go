@@ -0,0 +1,404 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: upgrader.proto
+
+package upgraderpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Upgrader_Upload_FullMethodName      = "/linker.upgrader.v1.Upgrader/Upload"
+	Upgrader_Upgrade_FullMethodName     = "/linker.upgrader.v1.Upgrader/Upgrade"
+	Upgrader_Status_FullMethodName      = "/linker.upgrader.v1.Upgrader/Status"
+	Upgrader_History_FullMethodName     = "/linker.upgrader.v1.Upgrader/History"
+	Upgrader_ListBackups_FullMethodName = "/linker.upgrader.v1.Upgrader/ListBackups"
+	Upgrader_Restore_FullMethodName     = "/linker.upgrader.v1.Upgrader/Restore"
+	Upgrader_Confirm_FullMethodName     = "/linker.upgrader.v1.Upgrader/Confirm"
+	Upgrader_SetChannel_FullMethodName  = "/linker.upgrader.v1.Upgrader/SetChannel"
+)
+
+// UpgraderClient is the client API for Upgrader service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// 程序升级系统 gRPC 接口
+type UpgraderClient interface {
+	// 上传升级包：第一条消息为 UploadInfo，之后为文件内容分块
+	Upload(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadRequest, UploadResponse], error)
+	// 使用已上传的升级包执行升级，升级过程中持续返回步骤与日志事件，最后一条为 finish 事件
+	Upgrade(ctx context.Context, in *UpgradeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[UpgradeEvent], error)
+	// 配置档状态，未指定 profile 时返回全部配置档
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
+	// 升级历史（最新的在前）
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
+	// 备份列表（最新的在前）
+	ListBackups(ctx context.Context, in *ListBackupsRequest, opts ...grpc.CallOption) (*ListBackupsResponse, error)
+	// 从备份恢复，未指定 backup 时使用最新的备份
+	Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*UpgradeResult, error)
+	// 确认待确认的升级，可以使用该配置档的 confirm_token 认证
+	Confirm(ctx context.Context, in *ConfirmRequest, opts ...grpc.CallOption) (*ConfirmResponse, error)
+	// 切换发布渠道，启用发布源时随后立即检查更新
+	SetChannel(ctx context.Context, in *SetChannelRequest, opts ...grpc.CallOption) (*SetChannelResponse, error)
+}
+
+type upgraderClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUpgraderClient(cc grpc.ClientConnInterface) UpgraderClient {
+	return &upgraderClient{cc}
+}
+
+func (c *upgraderClient) Upload(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[UploadRequest, UploadResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Upgrader_ServiceDesc.Streams[0], Upgrader_Upload_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UploadRequest, UploadResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Upgrader_UploadClient = grpc.ClientStreamingClient[UploadRequest, UploadResponse]
+
+func (c *upgraderClient) Upgrade(ctx context.Context, in *UpgradeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[UpgradeEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Upgrader_ServiceDesc.Streams[1], Upgrader_Upgrade_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[UpgradeRequest, UpgradeEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Upgrader_UpgradeClient = grpc.ServerStreamingClient[UpgradeEvent]
+
+func (c *upgraderClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatusResponse)
+	err := c.cc.Invoke(ctx, Upgrader_Status_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *upgraderClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HistoryResponse)
+	err := c.cc.Invoke(ctx, Upgrader_History_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *upgraderClient) ListBackups(ctx context.Context, in *ListBackupsRequest, opts ...grpc.CallOption) (*ListBackupsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListBackupsResponse)
+	err := c.cc.Invoke(ctx, Upgrader_ListBackups_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *upgraderClient) Restore(ctx context.Context, in *RestoreRequest, opts ...grpc.CallOption) (*UpgradeResult, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpgradeResult)
+	err := c.cc.Invoke(ctx, Upgrader_Restore_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *upgraderClient) Confirm(ctx context.Context, in *ConfirmRequest, opts ...grpc.CallOption) (*ConfirmResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfirmResponse)
+	err := c.cc.Invoke(ctx, Upgrader_Confirm_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *upgraderClient) SetChannel(ctx context.Context, in *SetChannelRequest, opts ...grpc.CallOption) (*SetChannelResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetChannelResponse)
+	err := c.cc.Invoke(ctx, Upgrader_SetChannel_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpgraderServer is the server API for Upgrader service.
+// All implementations must embed UnimplementedUpgraderServer
+// for forward compatibility.
+//
+// 程序升级系统 gRPC 接口
+type UpgraderServer interface {
+	// 上传升级包：第一条消息为 UploadInfo，之后为文件内容分块
+	Upload(grpc.ClientStreamingServer[UploadRequest, UploadResponse]) error
+	// 使用已上传的升级包执行升级，升级过程中持续返回步骤与日志事件，最后一条为 finish 事件
+	Upgrade(*UpgradeRequest, grpc.ServerStreamingServer[UpgradeEvent]) error
+	// 配置档状态，未指定 profile 时返回全部配置档
+	Status(context.Context, *StatusRequest) (*StatusResponse, error)
+	// 升级历史（最新的在前）
+	History(context.Context, *HistoryRequest) (*HistoryResponse, error)
+	// 备份列表（最新的在前）
+	ListBackups(context.Context, *ListBackupsRequest) (*ListBackupsResponse, error)
+	// 从备份恢复，未指定 backup 时使用最新的备份
+	Restore(context.Context, *RestoreRequest) (*UpgradeResult, error)
+	// 确认待确认的升级，可以使用该配置档的 confirm_token 认证
+	Confirm(context.Context, *ConfirmRequest) (*ConfirmResponse, error)
+	// 切换发布渠道，启用发布源时随后立即检查更新
+	SetChannel(context.Context, *SetChannelRequest) (*SetChannelResponse, error)
+	mustEmbedUnimplementedUpgraderServer()
+}
+
+// UnimplementedUpgraderServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedUpgraderServer struct{}
+
+func (UnimplementedUpgraderServer) Upload(grpc.ClientStreamingServer[UploadRequest, UploadResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedUpgraderServer) Upgrade(*UpgradeRequest, grpc.ServerStreamingServer[UpgradeEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Upgrade not implemented")
+}
+func (UnimplementedUpgraderServer) Status(context.Context, *StatusRequest) (*StatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedUpgraderServer) History(context.Context, *HistoryRequest) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedUpgraderServer) ListBackups(context.Context, *ListBackupsRequest) (*ListBackupsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBackups not implemented")
+}
+func (UnimplementedUpgraderServer) Restore(context.Context, *RestoreRequest) (*UpgradeResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Restore not implemented")
+}
+func (UnimplementedUpgraderServer) Confirm(context.Context, *ConfirmRequest) (*ConfirmResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Confirm not implemented")
+}
+func (UnimplementedUpgraderServer) SetChannel(context.Context, *SetChannelRequest) (*SetChannelResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetChannel not implemented")
+}
+func (UnimplementedUpgraderServer) mustEmbedUnimplementedUpgraderServer() {}
+func (UnimplementedUpgraderServer) testEmbeddedByValue()                  {}
+
+// UnsafeUpgraderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UpgraderServer will
+// result in compilation errors.
+type UnsafeUpgraderServer interface {
+	mustEmbedUnimplementedUpgraderServer()
+}
+
+func RegisterUpgraderServer(s grpc.ServiceRegistrar, srv UpgraderServer) {
+	// If the following call pancis, it indicates UnimplementedUpgraderServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Upgrader_ServiceDesc, srv)
+}
+
+func _Upgrader_Upload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(UpgraderServer).Upload(&grpc.GenericServerStream[UploadRequest, UploadResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Upgrader_UploadServer = grpc.ClientStreamingServer[UploadRequest, UploadResponse]
+
+func _Upgrader_Upgrade_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(UpgradeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UpgraderServer).Upgrade(m, &grpc.GenericServerStream[UpgradeRequest, UpgradeEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Upgrader_UpgradeServer = grpc.ServerStreamingServer[UpgradeEvent]
+
+func _Upgrader_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UpgraderServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Upgrader_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UpgraderServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Upgrader_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UpgraderServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Upgrader_History_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UpgraderServer).History(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Upgrader_ListBackups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBackupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UpgraderServer).ListBackups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Upgrader_ListBackups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UpgraderServer).ListBackups(ctx, req.(*ListBackupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Upgrader_Restore_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UpgraderServer).Restore(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Upgrader_Restore_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UpgraderServer).Restore(ctx, req.(*RestoreRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Upgrader_Confirm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UpgraderServer).Confirm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Upgrader_Confirm_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UpgraderServer).Confirm(ctx, req.(*ConfirmRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Upgrader_SetChannel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetChannelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UpgraderServer).SetChannel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Upgrader_SetChannel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UpgraderServer).SetChannel(ctx, req.(*SetChannelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Upgrader_ServiceDesc is the grpc.ServiceDesc for Upgrader service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Upgrader_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "linker.upgrader.v1.Upgrader",
+	HandlerType: (*UpgraderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Status",
+			Handler:    _Upgrader_Status_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _Upgrader_History_Handler,
+		},
+		{
+			MethodName: "ListBackups",
+			Handler:    _Upgrader_ListBackups_Handler,
+		},
+		{
+			MethodName: "Restore",
+			Handler:    _Upgrader_Restore_Handler,
+		},
+		{
+			MethodName: "Confirm",
+			Handler:    _Upgrader_Confirm_Handler,
+		},
+		{
+			MethodName: "SetChannel",
+			Handler:    _Upgrader_SetChannel_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Upload",
+			Handler:       _Upgrader_Upload_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Upgrade",
+			Handler:       _Upgrader_Upgrade_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "upgrader.proto",
+}
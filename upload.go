@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"linker-upgrader/upgrader"
+)
+
+const (
+	// 请求体中除文件外的表单字段和 multipart 边界允许的大小
+	uploadFormOverhead = 1 << 20
+	// 单个表单字段的最大长度
+	maxFormFieldSize = 4 << 10
+)
+
+// 上传请求缺少有效的 CSRF 令牌
+var errUploadCSRF = errors.New("CSRF 校验失败")
+
+// 上传过于频繁
+var errUploadRate = errors.New("请求过于频繁，请稍后再试")
+
+// 流式读取的上传表单
+type uploadForm struct {
+	upload *uploadMeta       // 随请求上传的文件，未上传时为 nil
+	fields map[string]string // 其他表单字段
+}
+
+func (f *uploadForm) value(name string) string {
+	return f.fields[name]
+}
+
+// 升级包来源：上传的文件、upload_id 指定的已完成的断点续传上传，或从 url 字段下载，
+// 并按 sha256 字段校验。发起方为 uploader
+func (f *uploadForm) source(ctx context.Context, uploader string) (*uploadMeta, int, error) {
+	var source *uploadMeta
+	switch {
+	case f.upload != nil:
+		source = f.upload
+	case f.value("upload_id") != "":
+		var err error
+		if source, err = loadUpload(f.value("upload_id")); err != nil {
+			return nil, http.StatusNotFound, err
+		}
+	case f.value("url") != "":
+		var err error
+		f.upload, err = downloadPackage(ctx, downloadRequest{
+			URL:      strings.TrimSpace(f.value("url")),
+			Filename: f.value("filename"),
+			Auth:     f.value("auth"),
+			Uploader: uploader,
+		})
+		if err != nil {
+			return nil, downloadStatus(err), err
+		}
+		source = f.upload
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("缺少文件")
+	}
+	if expect := strings.TrimSpace(f.value("sha256")); expect != "" && !strings.EqualFold(expect, source.SHA256) {
+		return nil, http.StatusBadRequest, fmt.Errorf("升级包 SHA256 校验失败")
+	}
+	return source, http.StatusOK, nil
+}
+
+// 删除随请求上传或下载的文件，升级之前失败时调用
+func (f *uploadForm) discard() {
+	if f.upload != nil {
+		removeUpload(f.upload.ID)
+		f.upload = nil
+	}
+}
+
+// 按顺序流式读取 multipart 请求体，文件直接写入磁盘并同时计算 SHA256，
+// 超过 maxSize 时立即返回 413，超过上传频率时返回 429。没有 API 令牌或 X-CSRF-Token 请求头时，
+// 表单字段 csrf_token 必须位于文件之前（页面表单即为此顺序），否则返回 errUploadCSRF。
+// 返回错误时已保存的文件被删除
+func readUploadForm(w http.ResponseWriter, r *http.Request, maxSize int64) (form *uploadForm, code int, err error) {
+	tooLarge := fmt.Errorf("文件大小超过限制 (%dMB)", appConfig.MaxFileSize)
+	// Content-Length 已超出时不读取请求体
+	if maxSize > 0 {
+		if r.ContentLength > maxSize+uploadFormOverhead {
+			log.Printf("拒绝上传: 请求大小 %d bytes 超过限制 (来自 %s)", r.ContentLength, r.RemoteAddr)
+			return nil, http.StatusRequestEntityTooLarge, tooLarge
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxSize+uploadFormOverhead)
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	form = &uploadForm{fields: make(map[string]string)}
+	defer func() {
+		if err != nil {
+			form.discard()
+			form = nil
+		}
+	}()
+	csrfChecked := csrfExempt(r)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return form, http.StatusRequestEntityTooLarge, tooLarge
+			}
+			return form, http.StatusBadRequest, err
+		}
+
+		switch name := part.FormName(); name {
+		case "file":
+			if !csrfChecked {
+				return form, http.StatusForbidden, errUploadCSRF
+			}
+			if form.upload != nil {
+				return form, http.StatusBadRequest, fmt.Errorf("只能上传一个文件")
+			}
+			if wait := access.uploadWait(r); wait > 0 {
+				setRetryAfter(w, wait)
+				return form, http.StatusTooManyRequests, errUploadRate
+			}
+			form.upload, err = storeUpload(part, part.FileName(), r.RemoteAddr, maxSize)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					log.Printf("拒绝上传: %s 超过大小限制 (来自 %s)", part.FileName(), r.RemoteAddr)
+					return form, http.StatusRequestEntityTooLarge, tooLarge
+				}
+				return form, http.StatusInternalServerError, err
+			}
+		case csrfFieldName:
+			csrfChecked = csrfChecked || validCSRFToken(r, readFormField(part))
+		default:
+			form.fields[name] = readFormField(part)
+		}
+		part.Close()
+	}
+
+	if !csrfChecked {
+		return form, http.StatusForbidden, errUploadCSRF
+	}
+	// 从 url 下载同样计入上传频率
+	if form.upload == nil && form.value("upload_id") == "" && form.value("url") != "" {
+		if wait := access.uploadWait(r); wait > 0 {
+			setRetryAfter(w, wait)
+			return form, http.StatusTooManyRequests, errUploadRate
+		}
+	}
+	return form, http.StatusOK, nil
+}
+
+// 上传并升级。不带文件时，使用 upload_id 字段指定的已完成的断点续传上传，
+// 或从 url 字段下载升级包（可选 filename、auth 字段）。sha256 字段用于校验升级包
+func uploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	profileName := r.URL.Query().Get("profile")
+	fail := func(code int, message string) {
+		profile := engine.Profile(profileName)
+		if profile == nil {
+			profile = engine.Profile("")
+		}
+		respondUpgrade(w, r, profile, &upgrader.UpgradeResult{Profile: profile.Name, Message: message}, code)
+	}
+
+	// 使用配置中的文件大小限制
+	form, code, err := readUploadForm(w, r, appConfig.MaxFileSize<<20)
+	if errors.Is(err, errUploadCSRF) {
+		rejectCSRF(w, r)
+		return
+	}
+	if err != nil {
+		fail(code, "上传失败："+err.Error())
+		return
+	}
+	// 升级之前失败时删除已保存的文件
+	defer form.discard()
+	if name, ok := form.fields["profile"]; ok {
+		profileName = name
+	}
+
+	profile := engine.Profile(profileName)
+	if profile == nil {
+		fail(http.StatusNotFound, "上传失败：配置档不存在")
+		return
+	}
+	uploaded := form.upload != nil
+	downloading := !uploaded && form.value("upload_id") == "" && form.value("url") != ""
+	if uploaded {
+		log.Printf("[%s] 上传文件: %s (ID: %s), 大小: %d bytes, SHA256: %s", profile.Name, form.upload.Filename, form.upload.ID, form.upload.Size, form.upload.SHA256)
+	} else if downloading {
+		log.Printf("[%s] 下载升级包: %s (来自 %s)", profile.Name, form.value("url"), r.RemoteAddr)
+	}
+	source, code, err := form.source(r.Context(), r.RemoteAddr)
+	switch {
+	case err != nil && downloading && form.upload == nil:
+		fail(code, "升级失败："+err.Error())
+		return
+	case err != nil:
+		fail(code, "上传失败："+err.Error())
+		return
+	case !uploaded && !downloading:
+		log.Printf("[%s] 使用断点续传上传: %s (ID: %s), 大小: %d bytes", profile.Name, source.Filename, source.ID, source.Size)
+	}
+
+	// 执行升级
+	req := source.upgradeRequest(profile.Name, form.value("force") == "true", r.RemoteAddr)
+	form.upload = nil
+	// 客户端断开连接不应中断进行中的升级
+	result, err := engine.Upgrade(context.WithoutCancel(r.Context()), req, nil)
+	respondUpgrade(w, r, profile, result, errorStatus(err))
+}
+
+func readFormField(r io.Reader) string {
+	b, _ := io.ReadAll(io.LimitReader(r, maxFormFieldSize))
+	return string(b)
+}
+
+// 下载错误对应的 HTTP 状态码
+func downloadStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidDownload):
+		return http.StatusBadRequest
+	case errors.Is(err, errDownloadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusBadGateway
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"linker-upgrader/upgrader"
+)
+
+// 文件名的最大长度（字节）
+const maxFilenameLength = 200
+
+var (
+	// 上传 ID 无效或对应的上传不存在
+	errUploadNotFound = errors.New("上传文件不存在")
+	// 断点续传上传尚未完成
+	errUploadIncomplete = errors.New("上传尚未完成")
+)
+
+// 上传文件的元数据：升级包以生成的 ID 命名，元数据保存在 <id>.json，
+// 客户端提供的文件名不参与路径拼接
+type uploadMeta struct {
+	ID       string    `json:"id"`
+	Filename string    `json:"filename"` // 清理后的原始文件名，仅用于显示和识别包格式
+	Size     int64     `json:"size"`
+	Length   int64     `json:"length,omitempty"` // 断点续传上传声明的总大小
+	SHA256   string    `json:"sha256"`
+	Uploader string    `json:"uploader"` // 上传方地址
+	Uploaded time.Time `json:"uploaded"`
+}
+
+// 升级包文件路径
+func (m *uploadMeta) path() string {
+	return uploadPath(m.ID)
+}
+
+func uploadPath(id string) string {
+	return filepath.Join(appConfig.UploadDir, id)
+}
+
+func uploadMetaPath(id string) string {
+	return filepath.Join(appConfig.UploadDir, id+".json")
+}
+
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成上传 ID 失败: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// 上传 ID 只能是 newUploadID 生成的格式
+func validUploadID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// 清理客户端提供的文件名：去掉目录部分（包括 Windows 路径）和控制字符，限制长度
+func sanitizeFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, " .")
+	for len(name) > maxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// 将升级包写入上传目录并计算 SHA256，成功后写入元数据文件。
+// 超过 maxSize 时返回 *http.MaxBytesError，任何失败都不会留下文件
+func storeUpload(src io.Reader, filename, uploader string, maxSize int64) (*uploadMeta, error) {
+	filename = sanitizeFilename(filename)
+	if filename == "" {
+		return nil, fmt.Errorf("文件名无效")
+	}
+	if err := os.MkdirAll(appConfig.UploadDir, upgrader.ParsePermission(appConfig.DirPermission)); err != nil {
+		return nil, fmt.Errorf("创建上传目录失败: %v", err)
+	}
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+	meta := &uploadMeta{ID: id, Filename: filename, Uploader: uploader}
+
+	dst, err := os.OpenFile(meta.path(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("创建文件失败: %v", err)
+	}
+	if maxSize > 0 {
+		// 多读一个字节用于判断是否超出限制
+		src = io.LimitReader(src, maxSize+1)
+	}
+	hash := sha256.New()
+	meta.Size, err = io.Copy(io.MultiWriter(dst, hash), src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && maxSize > 0 && meta.Size > maxSize {
+		err = &http.MaxBytesError{Limit: maxSize}
+	}
+	if err == nil {
+		meta.SHA256 = hex.EncodeToString(hash.Sum(nil))
+		meta.Uploaded = time.Now()
+		err = saveUploadMeta(meta)
+	}
+	if err != nil {
+		removeUpload(id)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("保存文件失败: %v", err)
+	}
+	return meta, nil
+}
+
+func saveUploadMeta(meta *uploadMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(uploadMetaPath(meta.ID), data, 0600)
+}
+
+func readUploadMeta(id string) (*uploadMeta, error) {
+	if !validUploadID(id) {
+		return nil, fmt.Errorf("%w: %s (ID 无效)", errUploadNotFound, id)
+	}
+	data, err := os.ReadFile(uploadMetaPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errUploadNotFound, id)
+	}
+	var meta uploadMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("读取上传元数据失败: %v", err)
+	}
+	meta.ID = id
+	return &meta, nil
+}
+
+// 读取已上传文件的元数据，ID 无效、文件不存在或断点续传尚未完成时返回错误
+func loadUpload(id string) (*uploadMeta, error) {
+	meta, err := readUploadMeta(id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(meta.path()); err != nil {
+		if _, err := os.Stat(uploadPartPath(id)); err == nil {
+			return nil, fmt.Errorf("%w: %s", errUploadIncomplete, id)
+		}
+		return nil, fmt.Errorf("%w: %s", errUploadNotFound, id)
+	}
+	return meta, nil
+}
+
+func removeUpload(id string) {
+	os.Remove(uploadPath(id))
+	os.Remove(uploadPartPath(id))
+	os.Remove(uploadMetaPath(id))
+}
+
+// 已上传文件对应的升级请求
+func (m *uploadMeta) upgradeRequest(profile string, force bool, remote string) upgrader.UpgradeRequest {
+	return upgrader.UpgradeRequest{
+		Profile:  profile,
+		FilePath: m.path(),
+		Filename: m.Filename,
+		SHA256:   m.SHA256,
+		Force:    force,
+		Remote:   remote,
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 测试使用临时上传目录
+func setupUploadDir(t *testing.T) {
+	t.Helper()
+	old := appConfig
+	appConfig = &Config{UploadDir: t.TempDir()}
+	appConfig.DirPermission = "0755"
+	t.Cleanup(func() { appConfig = old })
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"app-1.0.0.tar.gz", "app-1.0.0.tar.gz"},
+		{"../../etc/passwd", "passwd"},
+		{"/abs/path/app.zip", "app.zip"},
+		{`C:\Users\me\app.zip`, "app.zip"},
+		{`..\..\app.zip`, "app.zip"},
+		{"app\x00\n\r.zip", "app.zip"},
+		{"app\xff.zip", "app.zip"},
+		{"  app.zip. ", "app.zip"},
+		{"..", ""},
+		{"dir/", ""},
+		{"", ""},
+		{"升级包.tar.gz", "升级包.tar.gz"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFilename(tt.in); got != tt.want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	// 超长文件名按字符截断，不会截断多字节字符
+	long := sanitizeFilename(strings.Repeat("包", 100))
+	if len(long) > maxFilenameLength || !strings.HasPrefix(strings.Repeat("包", 100), long) {
+		t.Errorf("超长文件名截断错误: %d 字节", len(long))
+	}
+}
+
+func TestValidUploadID(t *testing.T) {
+	id, err := newUploadID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{id, true},
+		{strings.ToUpper(id), true},
+		{id[:31], false},
+		{id + "0", false},
+		{"../" + id[3:], false},
+		{strings.Repeat("g", 32), false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validUploadID(tt.id); got != tt.want {
+			t.Errorf("validUploadID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestStoreUpload(t *testing.T) {
+	setupUploadDir(t)
+
+	meta, err := storeUpload(strings.NewReader("hello"), "../app.tar.gz", "127.0.0.1", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Filename != "app.tar.gz" || meta.Size != 5 ||
+		meta.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("meta = %+v", meta)
+	}
+	loaded, err := loadUpload(meta.ID)
+	if err != nil || loaded.Filename != meta.Filename || loaded.SHA256 != meta.SHA256 {
+		t.Fatalf("loadUpload = %+v, %v", loaded, err)
+	}
+
+	// 超过大小限制时不留下任何文件
+	_, err = storeUpload(strings.NewReader("hello!"), "app.tar.gz", "127.0.0.1", 5)
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		t.Fatalf("err = %v, want MaxBytesError", err)
+	}
+	entries, _ := os.ReadDir(appConfig.UploadDir)
+	if len(entries) != 2 {
+		t.Errorf("上传目录应只有第一次上传的文件和元数据，实际 %d 个", len(entries))
+	}
+
+	if _, err := storeUpload(strings.NewReader("x"), "../", "127.0.0.1", 0); err == nil {
+		t.Error("文件名无效时应当报错")
+	}
+	if _, err := loadUpload("../" + meta.ID[3:]); !errors.Is(err, errUploadNotFound) {
+		t.Errorf("无效 ID: err = %v", err)
+	}
+}